@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupTime reads the save time from a backup file name.
+func backupTime(name string) (time.Time, error) {
+	return time.ParseInLocation("20060102-150405.000000000", strings.TrimSuffix(name, ".json"), time.Local)
+}
+
+// loadBackup reads and validates a backup.
+func loadBackup(name string) (*TimeData, error) {
+	dir, err := getBackupDir()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	data := &TimeData{}
+	if err := json.Unmarshal(raw, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ShowBackups lists the kept backups, newest first. The IDX column is what
+// RestoreBackup accepts.
+func ShowBackups() error {
+	backups, err := listBackups()
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]any{"backups": backupsJSON(backups)})
+	}
+
+	if len(backups) == 0 {
+		textln("No backups found")
+		return nil
+	}
+
+	textf("%-5s %-20s %s\n", "IDX", "SAVED", "ENTRIES")
+	textln(strings.Repeat("-", 40))
+
+	for i := len(backups) - 1; i >= 0; i-- {
+		name := backups[i]
+		saved := name
+		if t, err := backupTime(name); err == nil {
+			saved = t.Format("2006-01-02 15:04:05")
+		}
+
+		entries := "corrupt"
+		if data, err := loadBackup(name); err == nil {
+			entries = strconv.Itoa(len(data.Entries))
+		}
+
+		textf("%-5d %-20s %s\n", len(backups)-1-i, saved, entries)
+	}
+
+	return nil
+}
+
+// backupJSON describes a backup in --json output. Entries is null when the
+// backup cannot be read.
+type backupJSON struct {
+	Index   int        `json:"index"`
+	Name    string     `json:"name"`
+	Saved   *time.Time `json:"saved,omitempty"`
+	Entries *int       `json:"entries"`
+}
+
+// backupsJSON lists backups newest first, like ShowBackups.
+func backupsJSON(backups []string) []backupJSON {
+	out := make([]backupJSON, 0, len(backups))
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backupJSON{Index: len(backups) - 1 - i, Name: backups[i]}
+		if t, err := backupTime(b.Name); err == nil {
+			b.Saved = &t
+		}
+		if data, err := loadBackup(b.Name); err == nil {
+			n := len(data.Entries)
+			b.Entries = &n
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// RestoreBackup replaces the data file with a backup, given as an index from
+// ShowBackups (0 is the newest) or a file name. It saves like any other
+// command, so the current file is backed up first and the restore is
+// journaled and can be undone. A current file too damaged to load counts as
+// empty in the journal.
+func RestoreBackup(ref string) error {
+	path, err := getDataFilePath()
+	if err != nil {
+		return err
+	}
+	if isSQLitePath(path) {
+		return fmt.Errorf("backups are only kept for the JSON store; %s is a SQLite database", path)
+	}
+
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	backups, err := listBackups()
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var name string
+	if idx, err := strconv.Atoi(ref); err == nil {
+		if idx < 0 || idx >= len(backups) {
+			return withCode(codeNotFound, fmt.Errorf("invalid backup index: %d (valid range: 0-%d)", idx, len(backups)-1))
+		}
+		name = backups[len(backups)-1-idx]
+	} else {
+		for _, b := range backups {
+			if b == ref || b == ref+".json" {
+				name = b
+			}
+		}
+		if name == "" {
+			return withCode(codeNotFound, fmt.Errorf("no backup named %q", ref))
+		}
+	}
+
+	data, err := loadBackup(name)
+	if err != nil {
+		return fmt.Errorf("backup %s is unusable: %w", name, err)
+	}
+
+	if _, err := LoadData(); err != nil {
+		snapshotEntries(path, &TimeData{})
+	}
+	if err := SaveData(data); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]any{"restored": name, "entries": len(data.Entries)})
+	}
+	saved := name
+	if t, err := backupTime(name); err == nil {
+		saved = t.Format("2006-01-02 15:04:05")
+	}
+	textf("Restored backup from %s (%d entries)\n", saved, len(data.Entries))
+	return nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config holds user settings read from config.json in the config directory.
+// Every field is optional. Keys are edited with "timetrack config set" using
+// their dotted JSON names, e.g. rounding.minutes.
+type Config struct {
+	Currency    string                  `json:"currency,omitempty"`
+	WeekStart   string                  `json:"week_start,omitempty"`
+	DailyTarget string                  `json:"daily_target,omitempty"` // duration such as "7h30m"
+	List        ListConfig              `json:"list,omitempty"`
+	Display     DisplayConfig           `json:"display,omitempty"`
+	Rates       RateConfig              `json:"rates,omitempty"`
+	Rounding    RoundingConfig          `json:"rounding,omitempty"`
+	Clients     map[string]ClientConfig `json:"clients,omitempty"`
+	AutoStop    AutoStopConfig          `json:"auto_stop,omitempty"`
+	Hooks       HooksConfig             `json:"hooks,omitempty"`
+}
+
+// ListConfig holds defaults for the list command. Limit is a pointer so that
+// 0 (show all) can be told apart from unset.
+type ListConfig struct {
+	Limit *int `json:"limit,omitempty"`
+}
+
+// DisplayConfig controls how times are shown. DateFormat and TimeFormat are
+// Go layouts; Clock is 24h (the default) or 12h and picks the time layout
+// when TimeFormat is not set.
+type DisplayConfig struct {
+	DateFormat string `json:"date_format,omitempty"`
+	TimeFormat string `json:"time_format,omitempty"`
+	Clock      string `json:"clock,omitempty"`
+}
+
+// HooksConfig holds shell commands run after an entry starts or stops, with
+// the entry in TIMETRACK_* environment variables (see runHooks).
+type HooksConfig struct {
+	OnStart string `json:"on_start,omitempty"`
+	OnStop  string `json:"on_stop,omitempty"`
+}
+
+// configDefaults are the values "config get" reports for unset keys.
+var configDefaults = map[string]string{
+	"week_start":          "monday",
+	"list.limit":          "10",
+	"display.date_format": "2006-01-02",
+	"display.clock":       "24h",
+	"rounding.mode":       "up",
+}
+
+// Settings applied from the config at startup by applyConfig. Tests and
+// library callers that never load a config get the defaults.
+var (
+	dateLayout      = "2006-01-02"
+	clockLayout     = "15:04"
+	clockLayoutSecs = "15:04:05"
+	configWeekStart string
+	dailyTarget     time.Duration
+	hookCommands    HooksConfig
+)
+
+// applyConfig validates c and makes its display, week start, daily target
+// and hook settings take effect. Nothing changes if c is invalid.
+func applyConfig(c *Config) error {
+	if c.WeekStart != "" {
+		if _, err := parseWeekday(c.WeekStart); err != nil {
+			return fmt.Errorf("invalid week_start %q", c.WeekStart)
+		}
+	}
+	var target time.Duration
+	if c.DailyTarget != "" {
+		d, err := time.ParseDuration(c.DailyTarget)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid daily_target %q (expected a duration such as 8h)", c.DailyTarget)
+		}
+		target = d
+	}
+	if c.List.Limit != nil && *c.List.Limit < 0 {
+		return fmt.Errorf("invalid list.limit %d", *c.List.Limit)
+	}
+	switch c.Rounding.Mode {
+	case "", "up", "down", "nearest":
+	default:
+		return fmt.Errorf("invalid rounding.mode %q (expected up, down or nearest)", c.Rounding.Mode)
+	}
+	if _, _, err := c.AutoStop.CapFor(&TimeEntry{StartTime: time.Now()}); err != nil {
+		return err
+	}
+
+	clock, clockSecs := "15:04", "15:04:05"
+	switch c.Display.Clock {
+	case "", "24h":
+	case "12h":
+		clock, clockSecs = "3:04pm", "3:04:05pm"
+	default:
+		return fmt.Errorf("invalid display.clock %q (expected 12h or 24h)", c.Display.Clock)
+	}
+	if c.Display.TimeFormat != "" {
+		clock, clockSecs = c.Display.TimeFormat, c.Display.TimeFormat
+	}
+	date := "2006-01-02"
+	if c.Display.DateFormat != "" {
+		date = c.Display.DateFormat
+	}
+
+	dateLayout, clockLayout, clockLayoutSecs = date, clock, clockSecs
+	configWeekStart = c.WeekStart
+	dailyTarget = target
+	hookCommands = c.Hooks
+	return nil
+}
+
+func formatClock(t time.Time) string {
+	return t.Format(clockLayout)
+}
+
+func formatClockSeconds(t time.Time) string {
+	return t.Format(clockLayoutSecs)
+}
+
+func formatDateTime(t time.Time) string {
+	return t.Format(dateLayout + " " + clockLayout)
+}
+
+func formatDateTimeSeconds(t time.Time) string {
+	return t.Format(dateLayout + " " + clockLayoutSecs)
+}
+
+// RateConfig holds hourly rates. A tag rate wins over a project rate, which
+// wins over the default.
+type RateConfig struct {
+	Default  float64            `json:"default,omitempty"`
+	Projects map[string]float64 `json:"projects,omitempty"`
+	Tags     map[string]float64 `json:"tags,omitempty"`
+}
+
+// RoundingConfig rounds each entry's duration to a multiple of Minutes when
+// billing. Mode is up (the default), down or nearest.
+type RoundingConfig struct {
+	Minutes int    `json:"minutes,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+}
+
+// AutoStopConfig caps forgotten timers. MaxRunning is a duration such as
+// "10h"; EndOfDay is a clock time such as "19:00" after which a task started
+// that day counts as forgotten.
+type AutoStopConfig struct {
+	MaxRunning string `json:"max_running,omitempty"`
+	EndOfDay   string `json:"end_of_day,omitempty"`
+}
+
+// CapFor returns the time at which a running entry should have been stopped
+// under these rules, and false if no rule applies.
+func (a AutoStopConfig) CapFor(e *TimeEntry) (time.Time, bool, error) {
+	var limit time.Time
+	if a.MaxRunning != "" {
+		d, err := time.ParseDuration(a.MaxRunning)
+		if err != nil {
+			return limit, false, fmt.Errorf("invalid auto_stop.max_running %q", a.MaxRunning)
+		}
+		limit = e.StartTime.Add(d)
+	}
+	if a.EndOfDay != "" {
+		eod, err := parseClock(a.EndOfDay, startOfDay(e.StartTime))
+		if err != nil {
+			return limit, false, fmt.Errorf("invalid auto_stop.end_of_day %q", a.EndOfDay)
+		}
+		if !eod.After(e.StartTime) {
+			eod = eod.AddDate(0, 0, 1)
+		}
+		if limit.IsZero() || eod.Before(limit) {
+			limit = eod
+		}
+	}
+	return limit, !limit.IsZero(), nil
+}
+
+// ClientConfig describes who an invoice is addressed to. Without one, a
+// client name is taken to be a project name.
+type ClientConfig struct {
+	Name     string   `json:"name,omitempty"`
+	Address  string   `json:"address,omitempty"`
+	Projects []string `json:"projects,omitempty"`
+}
+
+// getConfigDir returns $XDG_CONFIG_HOME/timetrack, defaulting to
+// ~/.config/timetrack.
+func getConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "timetrack"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "timetrack"), nil
+}
+
+func LoadConfig() (*Config, error) {
+	dir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+
+	file, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(file, config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Join(dir, "config.json"), err)
+	}
+	return config, nil
+}
+
+// RateFor returns the hourly rate that applies to an entry.
+func (c *Config) RateFor(e *TimeEntry) float64 {
+	for _, tag := range e.Tags {
+		if rate, ok := c.Rates.Tags[tag]; ok {
+			return rate
+		}
+	}
+	if rate, ok := c.Rates.Projects[e.Project]; ok {
+		return rate
+	}
+	return c.Rates.Default
+}
+
+// Round applies the rounding rule to a billed duration.
+func (r RoundingConfig) Round(d time.Duration) time.Duration {
+	if r.Minutes <= 0 {
+		return d
+	}
+	unit := time.Duration(r.Minutes) * time.Minute
+	units := float64(d) / float64(unit)
+	switch r.Mode {
+	case "down":
+		units = math.Floor(units)
+	case "nearest":
+		units = math.Round(units)
+	default:
+		units = math.Ceil(units)
+	}
+	return time.Duration(units) * unit
+}
+
+// ClientProjects returns the projects billed to a client.
+func (c *Config) ClientProjects(client string) []string {
+	if cc, ok := c.Clients[client]; ok && len(cc.Projects) > 0 {
+		return cc.Projects
+	}
+	return []string{client}
+}
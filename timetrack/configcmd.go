@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// getConfigPath returns the path of config.json.
+func getConfigPath() (string, error) {
+	dir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// readRawConfig reads config.json as a generic JSON object, so config set
+// can edit it without dropping keys it does not know about.
+func readRawConfig() (map[string]any, error) {
+	path, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]any)
+	file, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return raw, nil
+		}
+		return nil, err
+	}
+	if len(bytes.TrimSpace(file)) == 0 {
+		return raw, nil
+	}
+	if err := json.Unmarshal(file, &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return raw, nil
+}
+
+// validateRawConfig checks that raw decodes into a Config with no unknown
+// keys and valid values.
+func validateRawConfig(raw map[string]any) error {
+	file, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(file))
+	dec.DisallowUnknownFields()
+	config := &Config{}
+	if err := dec.Decode(config); err != nil {
+		return err
+	}
+	return applyConfig(config)
+}
+
+func writeRawConfig(raw map[string]any) error {
+	path, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	// Hooks are shell commands, so keep ">" and "&" readable
+	var file bytes.Buffer
+	enc := json.NewEncoder(&file)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(raw); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, file.Bytes())
+}
+
+// lookupConfig walks a dotted key such as rounding.minutes.
+func lookupConfig(raw map[string]any, key string) (any, bool) {
+	var value any = raw
+	for _, part := range strings.Split(key, ".") {
+		section, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = section[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// flattenConfig lists the leaves under prefix as dotted keys.
+func flattenConfig(prefix string, value any, out map[string]any) {
+	section, ok := value.(map[string]any)
+	if !ok {
+		out[prefix] = value
+		return
+	}
+	for k, v := range section {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		flattenConfig(key, v, out)
+	}
+}
+
+// formatConfigValue shows strings bare and everything else as JSON.
+func formatConfigValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// ConfigList prints every key set in config.json.
+func ConfigList() error {
+	raw, err := readRawConfig()
+	if err != nil {
+		return err
+	}
+
+	flat := make(map[string]any)
+	flattenConfig("", raw, flat)
+	if jsonOutput {
+		return printJSON(map[string]any{"settings": flat})
+	}
+	if len(flat) == 0 {
+		path, _ := getConfigPath()
+		textf("No settings in %s\n", path)
+		return nil
+	}
+
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		textf("%s = %s\n", k, formatConfigValue(flat[k]))
+	}
+	return nil
+}
+
+// ConfigGet prints one setting, or its default when it is not set.
+func ConfigGet(key string) error {
+	raw, err := readRawConfig()
+	if err != nil {
+		return err
+	}
+
+	value, ok := lookupConfig(raw, key)
+	if !ok {
+		if def, ok := configDefaults[key]; ok {
+			if jsonOutput {
+				var value any = def
+				json.Unmarshal([]byte(def), &value)
+				return printJSON(map[string]any{"key": key, "value": value, "default": true})
+			}
+			textln(def)
+			return nil
+		}
+		return withCode(codeNotFound, fmt.Errorf("%s is not set", key))
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]any{"key": key, "value": value, "default": false})
+	}
+	if section, ok := value.(map[string]any); ok {
+		flat := make(map[string]any)
+		flattenConfig(key, section, flat)
+		keys := make([]string, 0, len(flat))
+		for k := range flat {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			textf("%s = %s\n", k, formatConfigValue(flat[k]))
+		}
+		return nil
+	}
+	textln(formatConfigValue(value))
+	return nil
+}
+
+// ConfigSet sets a dotted key. The value is taken as JSON when it parses as
+// JSON and fits the setting (numbers, true/false, lists), and as a plain
+// string otherwise.
+func ConfigSet(key, value string) error {
+	raw, err := readRawConfig()
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(key, ".")
+	section := raw
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := section[part]
+		if !ok {
+			next = make(map[string]any)
+			section[part] = next
+		}
+		if section, ok = next.(map[string]any); !ok {
+			return fmt.Errorf("%s is not a section", part)
+		}
+	}
+	last := parts[len(parts)-1]
+
+	var parsed any
+	if json.Unmarshal([]byte(value), &parsed) == nil {
+		section[last] = parsed
+		err = validateRawConfig(raw)
+	}
+	if parsed == nil || err != nil {
+		section[last] = value
+		err = validateRawConfig(raw)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	if err := writeRawConfig(raw); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if jsonOutput {
+		return printJSON(map[string]any{"key": key, "value": section[last]})
+	}
+	textf("%s = %s\n", key, formatConfigValue(section[last]))
+	return nil
+}
+
+// ConfigUnset removes a dotted key, restoring its default.
+func ConfigUnset(key string) error {
+	raw, err := readRawConfig()
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(key, ".")
+	parent := raw
+	if len(parts) > 1 {
+		value, ok := lookupConfig(raw, strings.Join(parts[:len(parts)-1], "."))
+		if parent, ok = value.(map[string]any); !ok {
+			return withCode(codeNotFound, fmt.Errorf("%s is not set", key))
+		}
+	}
+	last := parts[len(parts)-1]
+	if _, ok := parent[last]; !ok {
+		return withCode(codeNotFound, fmt.Errorf("%s is not set", key))
+	}
+	delete(parent, last)
+
+	if err := writeRawConfig(raw); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if jsonOutput {
+		return printJSON(map[string]any{"unset": key})
+	}
+	textf("Unset %s\n", key)
+	return nil
+}
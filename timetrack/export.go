@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportRecord is the flat shape of a TimeEntry shared by the CSV and JSON
+// Lines exports. Running entries are exported with their duration so far.
+type exportRecord struct {
+	ID              string     `json:"id"`
+	Title           string     `json:"title"`
+	Project         string     `json:"project,omitempty"`
+	Tags            []string   `json:"tags,omitempty"`
+	Start           time.Time  `json:"start"`
+	End             *time.Time `json:"end"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	Notes           string     `json:"notes,omitempty"`
+	Running         bool       `json:"running"`
+}
+
+func newExportRecord(e *TimeEntry) exportRecord {
+	return exportRecord{
+		ID:              e.ID,
+		Title:           e.Title,
+		Project:         e.Project,
+		Tags:            e.Tags,
+		Start:           e.StartTime,
+		End:             e.EndTime,
+		DurationSeconds: int64(e.Duration().Seconds()),
+		Notes:           e.Notes,
+		Running:         e.IsRunning(),
+	}
+}
+
+// ExportEntries writes the entries starting in [from, to) to output (stdout
+// when empty) in the given format, oldest first. Zero bounds are open.
+func ExportEntries(format string, from, to time.Time, output string) error {
+	var write func(io.Writer, []TimeEntry) error
+	switch format {
+	case "csv":
+		write = writeCSV
+	case "json":
+		write = writeJSON
+	case "jsonl":
+		write = writeJSONL
+	case "ics":
+		write = writeICS
+	default:
+		return fmt.Errorf("invalid format %q (expected csv, json, jsonl or ics)", format)
+	}
+
+	data, err := LoadDataBetween(from, to)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	var entries []TimeEntry
+	for _, entry := range data.Entries {
+		if !from.IsZero() && entry.StartTime.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !entry.StartTime.Before(to) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartTime.Before(entries[j].StartTime)
+	})
+
+	out := os.Stdout
+	if output != "" && output != "-" {
+		out, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	w := bufio.NewWriter(out)
+	if err := write(w, entries); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(map[string]any{"exported": len(entries), "output": output})
+		}
+		textf("Exported %d entries to %s\n", len(entries), output)
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, entries []TimeEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "title", "project", "tags", "start", "end", "duration_seconds", "notes", "running"})
+	for i := range entries {
+		r := newExportRecord(&entries[i])
+		end := ""
+		if r.End != nil {
+			end = r.End.Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			r.ID,
+			r.Title,
+			r.Project,
+			strings.Join(r.Tags, " "),
+			r.Start.Format(time.RFC3339),
+			end,
+			strconv.FormatInt(r.DurationSeconds, 10),
+			r.Notes,
+			strconv.FormatBool(r.Running),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON writes the entries as a single JSON array, the shape --json
+// output uses.
+func writeJSON(w io.Writer, entries []TimeEntry) error {
+	records := make([]exportRecord, 0, len(entries))
+	for i := range entries {
+		records = append(records, newExportRecord(&entries[i]))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func writeJSONL(w io.Writer, entries []TimeEntry) error {
+	enc := json.NewEncoder(w)
+	for i := range entries {
+		if err := enc.Encode(newExportRecord(&entries[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeICS renders entries as an iCalendar (RFC 5545) feed with one VEVENT
+// per entry. Running entries end at the time of export.
+func writeICS(w io.Writer, entries []TimeEntry) error {
+	const stamp = "20060102T150405Z"
+	now := time.Now().UTC()
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//timetrack//timetrack//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	for _, entry := range entries {
+		end := now
+		if entry.EndTime != nil {
+			end = entry.EndTime.UTC()
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+entry.ID+"@timetrack",
+			"DTSTAMP:"+now.Format(stamp),
+			"DTSTART:"+entry.StartTime.UTC().Format(stamp),
+			"DTEND:"+end.Format(stamp),
+			"SUMMARY:"+icsEscape(entry.Title),
+		)
+		if entry.Notes != "" {
+			lines = append(lines, "DESCRIPTION:"+icsEscape(entry.Notes))
+		}
+		if entry.Project != "" || len(entry.Tags) > 0 {
+			var categories []string
+			if entry.Project != "" {
+				categories = append(categories, icsEscape(entry.Project))
+			}
+			for _, tag := range entry.Tags {
+				categories = append(categories, icsEscape(tag))
+			}
+			lines = append(lines, "CATEGORIES:"+strings.Join(categories, ","))
+		}
+		if entry.IsRunning() {
+			lines = append(lines, "STATUS:TENTATIVE")
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, icsFold(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icsFold splits a content line into 75-octet chunks joined by CRLF and a
+// space, without breaking UTF-8 sequences.
+func icsFold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hook is a configured command waiting to run for an entry.
+type hook struct {
+	command string
+	env     []string
+}
+
+// pendingHooks are queued while the data file is locked and run once the
+// lock is released, so a hook can call timetrack itself without deadlocking.
+var pendingHooks []hook
+
+// queueHook schedules the configured hook for event ("start" or "stop"),
+// passing the entry in TIMETRACK_* environment variables.
+func queueHook(event string, e *TimeEntry) {
+	command := hookCommands.OnStart
+	if event == "stop" {
+		command = hookCommands.OnStop
+	}
+	if command == "" {
+		return
+	}
+
+	env := []string{
+		"TIMETRACK_EVENT=" + event,
+		"TIMETRACK_ID=" + e.ID,
+		"TIMETRACK_TITLE=" + e.Title,
+		"TIMETRACK_PROJECT=" + e.Project,
+		"TIMETRACK_TAGS=" + strings.Join(e.Tags, ","),
+		"TIMETRACK_START=" + e.StartTime.Format(time.RFC3339),
+	}
+	if e.EndTime != nil {
+		env = append(env,
+			"TIMETRACK_END="+e.EndTime.Format(time.RFC3339),
+			"TIMETRACK_DURATION="+strconv.FormatInt(int64(e.Duration().Seconds()), 10))
+	}
+	pendingHooks = append(pendingHooks, hook{command: command, env: env})
+}
+
+// runHooks runs the queued hooks through the shell. Their output goes to
+// stderr, and a failing hook is reported without failing the command.
+func runHooks() {
+	hooks := pendingHooks
+	pendingHooks = nil
+
+	for _, h := range hooks {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", h.command)
+		} else {
+			cmd = exec.Command("sh", "-c", h.command)
+		}
+		cmd.Env = append(os.Environ(), h.env...)
+		cmd.Stdout = warningOut
+		cmd.Stderr = warningOut
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(warningOut, "Warning: hook %q failed: %v\n", h.command, err)
+		}
+	}
+}
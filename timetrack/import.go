@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// importers parse another tracker's export into entries. Entries without an
+// EndTime were still running in the other tracker and are not imported.
+var importers = map[string]func([]byte) ([]TimeEntry, error){
+	"toggl":       parseToggl,
+	"clockify":    parseClockify,
+	"timewarrior": parseTimewarrior,
+}
+
+// ImportEntries adds the entries from another tracker's export file ("-" for
+// stdin). An entry
+// with the same start time and title as an existing one is skipped, so
+// importing the same file twice is harmless; an entry that overlaps a
+// different existing entry is reported as a conflict and not added.
+func ImportEntries(source, path string, dryRun bool) error {
+	parse, ok := importers[source]
+	if !ok {
+		return fmt.Errorf("invalid source %q (expected toggl, clockify or timewarrior)", source)
+	}
+
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\ufeff"))
+
+	imported, err := parse(raw)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s export: %w", source, err)
+	}
+
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range data.Entries {
+		seen[importKey(&entry)] = true
+	}
+
+	var added, skipped, running int
+	var conflicts, invalid []string
+	for _, entry := range imported {
+		if entry.EndTime == nil {
+			running++
+			continue
+		}
+		if entry.EndTime.Before(entry.StartTime) {
+			invalid = append(invalid, fmt.Sprintf("%s %s ends before it starts (%s)",
+				entry.StartTime.Format("2006-01-02 15:04"), entry.Title, entry.EndTime.Format("2006-01-02 15:04")))
+			continue
+		}
+		key := importKey(&entry)
+		if seen[key] {
+			skipped++
+			continue
+		}
+		if other := findOverlap(data, entry.StartTime, *entry.EndTime, nil); other != nil {
+			conflicts = append(conflicts, fmt.Sprintf("%s %s overlaps %s [%s]",
+				entry.StartTime.Format("2006-01-02 15:04"), entry.Title, other.Title, other.ID))
+			continue
+		}
+		entry.ID = generateID()
+		data.Entries = append(data.Entries, entry)
+		seen[key] = true
+		added++
+	}
+
+	verb := "Imported"
+	if dryRun {
+		verb = "Would import"
+	} else if added > 0 {
+		if err := SaveData(data); err != nil {
+			return fmt.Errorf("failed to save data: %w", err)
+		}
+	}
+
+	if jsonOutput {
+		if conflicts == nil {
+			conflicts = []string{}
+		}
+		return printJSON(map[string]any{
+			"imported":  added,
+			"skipped":   skipped,
+			"conflicts": conflicts,
+			"running":   running,
+			"invalid":   len(invalid),
+			"dry_run":   dryRun,
+		})
+	}
+
+	textf("%s %d entries from %s (%d already present, %d conflicting", verb, added, source, skipped, len(conflicts))
+	if running > 0 {
+		textf(", %d still running", running)
+	}
+	if len(invalid) > 0 {
+		textf(", %d invalid", len(invalid))
+	}
+	textln(")")
+	for _, conflict := range conflicts {
+		textf("  conflict: %s\n", conflict)
+	}
+	for _, line := range invalid {
+		textf("  invalid: %s\n", line)
+	}
+	return nil
+}
+
+// importKey identifies an entry for de-duplication across imports.
+func importKey(e *TimeEntry) string {
+	return e.StartTime.Truncate(time.Second).UTC().Format(time.RFC3339) + "|" + e.Title
+}
+
+// isJSON reports whether raw looks like a JSON document rather than CSV.
+func isJSON(raw []byte) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{')
+}
+
+// csvTable gives access to CSV rows by (case-insensitive) column name.
+type csvTable struct {
+	columns map[string]int
+	rows    [][]string
+}
+
+func readCSVTable(raw []byte) (*csvTable, error) {
+	r := csv.NewReader(bytes.NewReader(raw))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+	table := &csvTable{columns: make(map[string]int), rows: records[1:]}
+	for i, name := range records[0] {
+		table.columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return table, nil
+}
+
+// get returns the first of the named columns present in the row.
+func (t *csvTable) get(row []string, names ...string) string {
+	for _, name := range names {
+		if i, ok := t.columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+	}
+	return ""
+}
+
+func (t *csvTable) require(names ...string) error {
+	for _, name := range names {
+		if _, ok := t.columns[name]; !ok {
+			return fmt.Errorf("missing column %q", name)
+		}
+	}
+	return nil
+}
+
+var importDateLayouts = []string{"2006-01-02", "01/02/2006", "02.01.2006"}
+var importClockLayouts = []string{"15:04:05", "15:04", "03:04:05 PM", "3:04:05 PM", "03:04 PM", "3:04 PM"}
+
+// parseDateClock combines separate date and time columns in local time.
+func parseDateClock(date, clock string) (time.Time, error) {
+	for _, dl := range importDateLayouts {
+		for _, cl := range importClockLayouts {
+			if t, err := time.ParseInLocation(dl+" "+cl, date+" "+clock, time.Local); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date/time %q %q", date, clock)
+}
+
+// splitTags splits a comma separated tag column, turning spaces inside a
+// tag into dashes so it stays a single +tag word.
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.Join(strings.Fields(tag), "-")
+		if tag != "" {
+			tags = addTag(tags, tag)
+		}
+	}
+	return tags
+}
+
+// importedEntry builds an entry from the common fields of the importers.
+func importedEntry(title, project string, tags []string, start time.Time, end *time.Time) TimeEntry {
+	if title == "" {
+		title = "(no description)"
+	}
+	return TimeEntry{
+		Title:     title,
+		Project:   strings.Join(strings.Fields(project), "-"),
+		Tags:      tags,
+		StartTime: start,
+		EndTime:   end,
+	}
+}
+
+// parseCSVRows maps the rows of a Toggl or Clockify CSV report, which differ
+// only in their column names.
+func parseCSVRows(raw []byte, startDate, startClock, endDate, endClock string) ([]TimeEntry, error) {
+	table, err := readCSVTable(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.require("description", startDate, startClock); err != nil {
+		return nil, err
+	}
+
+	var entries []TimeEntry
+	for n, row := range table.rows {
+		start, err := parseDateClock(table.get(row, startDate), table.get(row, startClock))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", n+2, err)
+		}
+		var end *time.Time
+		if table.get(row, endClock) != "" {
+			t, err := parseDateClock(table.get(row, endDate), table.get(row, endClock))
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", n+2, err)
+			}
+			end = &t
+		}
+		entries = append(entries, importedEntry(
+			table.get(row, "description"),
+			table.get(row, "project"),
+			splitTags(table.get(row, "tags")),
+			start, end,
+		))
+	}
+	return entries, nil
+}
+
+// parseToggl reads a Toggl Track detailed CSV report or a JSON array of
+// time entries from the Toggl API.
+func parseToggl(raw []byte) ([]TimeEntry, error) {
+	if !isJSON(raw) {
+		return parseCSVRows(raw, "start date", "start time", "end date", "end time")
+	}
+
+	var records []struct {
+		Description string     `json:"description"`
+		Start       time.Time  `json:"start"`
+		Stop        *time.Time `json:"stop"`
+		Project     string     `json:"project_name"`
+		Tags        []string   `json:"tags"`
+	}
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, err
+	}
+
+	var entries []TimeEntry
+	for _, r := range records {
+		entries = append(entries, importedEntry(r.Description, r.Project, splitTags(strings.Join(r.Tags, ",")), r.Start.Local(), localTime(r.Stop)))
+	}
+	return entries, nil
+}
+
+// parseClockify reads a Clockify detailed CSV report or a JSON array of time
+// entries from the Clockify API (with hydrated project and tags).
+func parseClockify(raw []byte) ([]TimeEntry, error) {
+	if !isJSON(raw) {
+		return parseCSVRows(raw, "start date", "start time", "end date", "end time")
+	}
+
+	var records []struct {
+		Description  string `json:"description"`
+		TimeInterval struct {
+			Start time.Time  `json:"start"`
+			End   *time.Time `json:"end"`
+		} `json:"timeInterval"`
+		Project *struct {
+			Name string `json:"name"`
+		} `json:"project"`
+		Tags []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+	}
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, err
+	}
+
+	var entries []TimeEntry
+	for _, r := range records {
+		project := ""
+		if r.Project != nil {
+			project = r.Project.Name
+		}
+		var tags []string
+		for _, tag := range r.Tags {
+			tags = append(tags, tag.Name)
+		}
+		entries = append(entries, importedEntry(r.Description, project, splitTags(strings.Join(tags, ",")),
+			r.TimeInterval.Start.Local(), localTime(r.TimeInterval.End)))
+	}
+	return entries, nil
+}
+
+// parseTimewarrior reads the output of `timew export`. Timewarrior has no
+// title, so the annotation is used, falling back to the first tag.
+func parseTimewarrior(raw []byte) ([]TimeEntry, error) {
+	const layout = "20060102T150405Z"
+
+	var records []struct {
+		Start      string   `json:"start"`
+		End        string   `json:"end"`
+		Tags       []string `json:"tags"`
+		Annotation string   `json:"annotation"`
+	}
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, err
+	}
+
+	var entries []TimeEntry
+	for i, r := range records {
+		start, err := time.Parse(layout, r.Start)
+		if err != nil {
+			return nil, fmt.Errorf("interval %d: invalid start %q", i+1, r.Start)
+		}
+		var end *time.Time
+		if r.End != "" {
+			t, err := time.Parse(layout, r.End)
+			if err != nil {
+				return nil, fmt.Errorf("interval %d: invalid end %q", i+1, r.End)
+			}
+			end = &t
+		}
+
+		title, tags := r.Annotation, r.Tags
+		if title == "" && len(tags) > 0 {
+			title, tags = tags[0], tags[1:]
+		}
+		entries = append(entries, importedEntry(title, "", splitTags(strings.Join(tags, ",")), start.Local(), localTime(end)))
+	}
+	return entries, nil
+}
+
+func localTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.Local()
+	return &local
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"time"
+)
+
+// Invoice is the data handed to the invoice templates.
+type Invoice struct {
+	Client     string           `json:"client"`
+	ClientName string           `json:"client_name"`
+	Address    string           `json:"address,omitempty"`
+	Period     string           `json:"period"`
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	Currency   string           `json:"currency"`
+	Sections   []InvoiceSection `json:"sections"`
+	Hours      float64          `json:"hours"`
+	Total      float64          `json:"total"`
+	Generated  time.Time        `json:"generated"`
+}
+
+// InvoiceSection groups the line items of one project.
+type InvoiceSection struct {
+	Project  string        `json:"project"`
+	Items    []InvoiceItem `json:"items"`
+	Hours    float64       `json:"hours"`
+	Subtotal float64       `json:"subtotal"`
+}
+
+// InvoiceItem is one line: all billable entries with the same title and rate.
+type InvoiceItem struct {
+	Title   string  `json:"title"`
+	Entries int     `json:"entries"`
+	Hours   float64 `json:"hours"`
+	Rate    float64 `json:"rate"`
+	Amount  float64 `json:"amount"`
+}
+
+const markdownInvoiceTemplate = `# Invoice: {{.ClientName}}
+
+{{if .Address}}{{.Address}}
+
+{{end}}**Period:** {{.Period}} ({{.From.Format "2006-01-02"}} to {{.To.Format "2006-01-02"}})
+**Generated:** {{.Generated.Format "2006-01-02"}}
+{{range .Sections}}
+## {{.Project}}
+
+| Item | Entries | Hours | Rate | Amount |
+|------|--------:|------:|-----:|-------:|
+{{range .Items}}| {{.Title}} | {{.Entries}} | {{hours .Hours}} | {{money .Rate}} | {{money .Amount}} |
+{{end}}| **Subtotal** | | **{{hours .Hours}}** | | **{{money .Subtotal}}** |
+{{end}}
+**Total: {{hours .Hours}} hours, {{.Currency}} {{money .Total}}**
+`
+
+const htmlInvoiceTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice: {{.ClientName}} {{.Period}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { border-bottom: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+td.num, th.num { text-align: right; }
+tr.subtotal td { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Invoice: {{.ClientName}}</h1>
+{{if .Address}}<p>{{.Address}}</p>{{end}}
+<p>Period: {{.Period}} ({{.From.Format "2006-01-02"}} to {{.To.Format "2006-01-02"}})<br>
+Generated: {{.Generated.Format "2006-01-02"}}</p>
+{{range .Sections}}
+<h2>{{.Project}}</h2>
+<table>
+<tr><th>Item</th><th class="num">Entries</th><th class="num">Hours</th><th class="num">Rate</th><th class="num">Amount</th></tr>
+{{range .Items}}<tr><td>{{.Title}}</td><td class="num">{{.Entries}}</td><td class="num">{{hours .Hours}}</td><td class="num">{{money .Rate}}</td><td class="num">{{money .Amount}}</td></tr>
+{{end}}<tr class="subtotal"><td>Subtotal</td><td></td><td class="num">{{hours .Hours}}</td><td></td><td class="num">{{money .Subtotal}}</td></tr>
+</table>
+{{end}}
+<h2>Total: {{hours .Hours}} hours, {{.Currency}} {{money .Total}}</h2>
+</body>
+</html>
+`
+
+var invoiceFuncs = map[string]any{
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"hours": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}
+
+// BuildInvoice collects the billable entries of a client's projects that
+// fall in [from, to), rounding each entry and pricing it at its rate.
+func BuildInvoice(data *TimeData, config *Config, client string, from, to time.Time, period string) *Invoice {
+	invoice := &Invoice{
+		Client:     client,
+		ClientName: client,
+		Period:     period,
+		From:       from,
+		To:         to.Add(-time.Second), // last day covered, for display
+		Currency:   config.Currency,
+		Generated:  time.Now(),
+	}
+	if cc, ok := config.Clients[client]; ok {
+		if cc.Name != "" {
+			invoice.ClientName = cc.Name
+		}
+		invoice.Address = cc.Address
+	}
+	if invoice.Currency == "" {
+		invoice.Currency = "USD"
+	}
+
+	projects := make(map[string]bool)
+	for _, p := range config.ClientProjects(client) {
+		projects[p] = true
+	}
+
+	type itemKey struct {
+		title string
+		rate  float64
+	}
+	sections := make(map[string]map[itemKey]*InvoiceItem)
+
+	for _, entry := range data.Entries {
+		if !projects[entry.Project] || !entry.IsBillable() || entry.IsRunning() {
+			continue
+		}
+		d := entry.DurationWithin(from, to)
+		if d == 0 {
+			continue
+		}
+		d = config.Rounding.Round(d)
+
+		if sections[entry.Project] == nil {
+			sections[entry.Project] = make(map[itemKey]*InvoiceItem)
+		}
+		key := itemKey{entry.Title, config.RateFor(&entry)}
+		item, ok := sections[entry.Project][key]
+		if !ok {
+			item = &InvoiceItem{Title: entry.Title, Rate: key.rate}
+			sections[entry.Project][key] = item
+		}
+		item.Entries++
+		item.Hours += d.Hours()
+	}
+
+	for project, items := range sections {
+		section := InvoiceSection{Project: project}
+		for _, item := range items {
+			item.Amount = math.Round(item.Hours*item.Rate*100) / 100
+			section.Items = append(section.Items, *item)
+			section.Hours += item.Hours
+			section.Subtotal += item.Amount
+		}
+		sort.Slice(section.Items, func(i, j int) bool {
+			if section.Items[i].Title != section.Items[j].Title {
+				return section.Items[i].Title < section.Items[j].Title
+			}
+			return section.Items[i].Rate < section.Items[j].Rate
+		})
+		invoice.Sections = append(invoice.Sections, section)
+		invoice.Hours += section.Hours
+		invoice.Total += section.Subtotal
+	}
+	sort.Slice(invoice.Sections, func(i, j int) bool {
+		return invoice.Sections[i].Project < invoice.Sections[j].Project
+	})
+
+	return invoice
+}
+
+// renderInvoice writes an invoice using invoice.<format>.tmpl from the config
+// directory if present, or the built-in template otherwise. The json format
+// is the invoice data itself.
+func renderInvoice(w io.Writer, invoice *Invoice, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(invoice)
+	}
+
+	builtin := markdownInvoiceTemplate
+	if format == "html" {
+		builtin = htmlInvoiceTemplate
+	}
+
+	text := builtin
+	if dir, err := getConfigDir(); err == nil {
+		if custom, err := os.ReadFile(filepath.Join(dir, "invoice."+format+".tmpl")); err == nil {
+			text = string(custom)
+		}
+	}
+
+	if format == "html" {
+		tmpl, err := htmltemplate.New("invoice").Funcs(invoiceFuncs).Parse(text)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, invoice)
+	}
+	tmpl, err := template.New("invoice").Funcs(invoiceFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, invoice)
+}
+
+// InvoiceClient renders an invoice for a client's billable time in
+// [from, to) to output (stdout when empty).
+func InvoiceClient(client string, from, to time.Time, period, format, output string) error {
+	if format != "md" && format != "html" && format != "json" {
+		return fmt.Errorf("invalid format %q (expected html, md or json)", format)
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	data, err := LoadDataBetween(from, to)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	invoice := BuildInvoice(data, config, client, from, to, period)
+	if len(invoice.Sections) == 0 {
+		return withCode(codeNotFound, fmt.Errorf("no billable entries for %s in %s", client, period))
+	}
+
+	out := os.Stdout
+	if output != "" && output != "-" {
+		out, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	if err := renderInvoice(out, invoice, format); err != nil {
+		return fmt.Errorf("failed to render invoice: %w", err)
+	}
+
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(map[string]any{"client": invoice.ClientName, "hours": invoice.Hours,
+				"currency": invoice.Currency, "total": invoice.Total, "output": output})
+		}
+		textf("Wrote invoice for %s (%.2f hours, %s %.2f) to %s\n",
+			invoice.ClientName, invoice.Hours, invoice.Currency, invoice.Total, output)
+	}
+	return nil
+}
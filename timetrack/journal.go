@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// journalRecord is one line of the journal: an operation and the entries it
+// changed. Undo and redo are operations too, reverting the record Reverts.
+type journalRecord struct {
+	Seq     int           `json:"seq"`
+	Time    time.Time     `json:"time"`
+	Op      string        `json:"op"`
+	Reverts int           `json:"reverts,omitempty"`
+	Changes []entryChange `json:"changes"`
+}
+
+// entryChange is an entry before and after an operation. Before is nil for
+// an added entry and After is nil for a deleted one.
+type entryChange struct {
+	Before *TimeEntry `json:"before"`
+	After  *TimeEntry `json:"after"`
+}
+
+// journalOp names the operation the next SaveData records, normally the
+// command being run; journalReverts is set by undo and redo.
+var (
+	journalOp      = "save"
+	journalReverts int
+)
+
+// loadedEntries is the encoding of every entry as LoadData last returned
+// it from loadedFrom, keyed by journalKey. SaveData diffs against it to find
+// what an operation changed.
+var (
+	loadedEntries map[string][]byte
+	loadedFrom    string
+)
+
+// journalKey identifies an entry across loads. Entries from before IDs
+// existed fall back to their start time.
+func journalKey(e *TimeEntry) string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return "@" + e.StartTime.Format(time.RFC3339Nano)
+}
+
+// snapshotEntries remembers data as loaded from path, for diffEntries.
+func snapshotEntries(path string, data *TimeData) {
+	loadedFrom = path
+	loadedEntries = make(map[string][]byte, len(data.Entries))
+	for i := range data.Entries {
+		if raw, err := json.Marshal(&data.Entries[i]); err == nil {
+			loadedEntries[journalKey(&data.Entries[i])] = raw
+		}
+	}
+}
+
+// diffEntries returns the entries of data that were added, changed or
+// removed since the last load.
+func diffEntries(data *TimeData) []entryChange {
+	var changes []entryChange
+	seen := make(map[string]bool, len(data.Entries))
+	for i := range data.Entries {
+		e := &data.Entries[i]
+		key := journalKey(e)
+		seen[key] = true
+		raw, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		before, ok := loadedEntries[key]
+		if ok && bytes.Equal(before, raw) {
+			continue
+		}
+		after := *e
+		changes = append(changes, entryChange{Before: decodeEntry(before), After: &after})
+	}
+	var removed []string
+	for key := range loadedEntries {
+		if !seen[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		changes = append(changes, entryChange{Before: decodeEntry(loadedEntries[key])})
+	}
+	return changes
+}
+
+func decodeEntry(raw []byte) *TimeEntry {
+	if raw == nil {
+		return nil
+	}
+	var e TimeEntry
+	if json.Unmarshal(raw, &e) != nil {
+		return nil
+	}
+	return &e
+}
+
+// getJournalPath returns the journal kept next to the data file.
+func getJournalPath(dataPath string) string {
+	return strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + ".journal"
+}
+
+// recordJournal appends what the save of data changed to the journal. The
+// data is already saved, so a journal that cannot be written only warns.
+func recordJournal(path string, data *TimeData) {
+	if loadedFrom != path {
+		loadedEntries = nil
+	}
+	changes := diffEntries(data)
+	snapshotEntries(path, data)
+	if len(changes) == 0 {
+		return
+	}
+
+	if err := appendJournal(path, changes); err != nil {
+		fmt.Fprintf(warningOut, "Warning: failed to update the journal, this change cannot be undone: %v\n", err)
+	}
+}
+
+func appendJournal(dataPath string, changes []entryChange) error {
+	path := getJournalPath(dataPath)
+	seq, err := lastJournalSeq(path)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(journalRecord{
+		Seq:     seq + 1,
+		Time:    time.Now(),
+		Op:      journalOp,
+		Reverts: journalReverts,
+		Changes: changes,
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lastJournalSeq returns the sequence number of the journal's last record,
+// reading only as much of the end of the file as that line needs.
+func lastJournalSeq(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	for chunk := int64(4096); ; chunk *= 2 {
+		chunk = min(chunk, size)
+		buf := make([]byte, chunk)
+		if _, err := f.ReadAt(buf, size-chunk); err != nil && err != io.EOF {
+			return 0, err
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		start := bytes.LastIndexByte(buf, '\n')
+		if start < 0 && chunk < size {
+			continue
+		}
+		if len(buf) == 0 {
+			return 0, nil
+		}
+		var last journalRecord
+		if err := json.Unmarshal(buf[start+1:], &last); err != nil {
+			return 0, fmt.Errorf("journal %s is corrupt: %w", path, err)
+		}
+		return last.Seq, nil
+	}
+}
+
+// readJournal returns every record in the journal, oldest first.
+func readJournal() ([]journalRecord, error) {
+	dataPath, err := getDataFilePath()
+	if err != nil {
+		return nil, err
+	}
+	path := getJournalPath(dataPath)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []journalRecord
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec journalRecord
+			if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+				return nil, fmt.Errorf("journal %s is corrupt at record %d: %w", path, len(records)+1, jsonErr)
+			}
+			records = append(records, rec)
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// undoStacks replays the journal into the operations that can be undone
+// (most recent last) and those that can be redone (next redo last). Any new
+// operation clears the redo stack, as in an editor.
+func undoStacks(records []journalRecord) (done, undone []journalRecord) {
+	for _, rec := range records {
+		switch rec.Op {
+		case "undo":
+			if n := len(done); n > 0 && done[n-1].Seq == rec.Reverts {
+				done, undone = done[:n-1], append(undone, done[n-1])
+			}
+		case "redo":
+			if n := len(undone); n > 0 && undone[n-1].Seq == rec.Reverts {
+				undone, done = undone[:n-1], append(done, undone[n-1])
+			}
+		default:
+			done, undone = append(done, rec), nil
+		}
+	}
+	return done, undone
+}
+
+// Undo reverts the most recent operation that has not been undone.
+func Undo() error {
+	return replayJournal("undo")
+}
+
+// Redo applies the most recently undone operation again.
+func Redo() error {
+	return replayJournal("redo")
+}
+
+// replayJournal undoes or redoes one operation. Every entry it touched must
+// still be as the operation left it (or, for redo, as the undo left it);
+// otherwise a later change would be silently lost, and it refuses.
+func replayJournal(op string) error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	records, err := readJournal()
+	if err != nil {
+		return err
+	}
+	done, undone := undoStacks(records)
+	stack := done
+	if op == "redo" {
+		stack = undone
+	}
+	if len(stack) == 0 {
+		return withCode(codeNotFound, fmt.Errorf("nothing to %s", op))
+	}
+	target := stack[len(stack)-1]
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	index := make(map[string]int, len(data.Entries))
+	for i := range data.Entries {
+		index[journalKey(&data.Entries[i])] = i
+	}
+
+	var removed []int
+	for i := len(target.Changes) - 1; i >= 0; i-- {
+		from, to := target.Changes[i].After, target.Changes[i].Before
+		if op == "redo" {
+			from, to = to, from
+		}
+		key := journalKey(firstEntry(from, to))
+
+		pos, exists := index[key]
+		var current *TimeEntry
+		if exists {
+			current = &data.Entries[pos]
+		}
+		if !sameEntry(current, from) {
+			return withCode(codeConflict, fmt.Errorf("cannot %s #%d %s: %q has changed since", op, target.Seq, target.Op, firstEntry(from, to).Title))
+		}
+
+		switch {
+		case to == nil:
+			removed = append(removed, pos)
+		case exists:
+			data.Entries[pos] = *to
+		default:
+			data.Entries = append(data.Entries, *to)
+			index[key] = len(data.Entries) - 1
+		}
+	}
+	data.Entries = removeEntries(data.Entries, removed)
+
+	prevOp, prevReverts := journalOp, journalReverts
+	journalOp, journalReverts = op, target.Seq
+	defer func() { journalOp, journalReverts = prevOp, prevReverts }()
+	if err := SaveData(data); err != nil {
+		return fmt.Errorf("failed to save data: %w", err)
+	}
+
+	verb := "Undid"
+	if op == "redo" {
+		verb = "Redid"
+	}
+	if jsonOutput {
+		return printJSON(map[string]any{strings.ToLower(verb): target.Seq, "op": target.Op, "changes": len(target.Changes)})
+	}
+	textf("%s #%d %s: %s\n", verb, target.Seq, target.Op, describeChanges(target.Changes))
+	return nil
+}
+
+// firstEntry returns whichever of a change's sides exists.
+func firstEntry(a, b *TimeEntry) *TimeEntry {
+	if a != nil {
+		return a
+	}
+	return b
+}
+
+// sameEntry reports whether current is the entry the journal expects,
+// where nil means the entry must not exist.
+func sameEntry(current, want *TimeEntry) bool {
+	if current == nil || want == nil {
+		return current == nil && want == nil
+	}
+	a, errA := json.Marshal(current)
+	b, errB := json.Marshal(want)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// removeEntries deletes the entries at the given positions.
+func removeEntries(entries []TimeEntry, positions []int) []TimeEntry {
+	if len(positions) == 0 {
+		return entries
+	}
+	drop := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		drop[p] = true
+	}
+	kept := entries[:0]
+	for i, e := range entries {
+		if !drop[i] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// describeChanges summarises a record's changes on one line, e.g.
+// "added Write docs, stopped Fix login".
+func describeChanges(changes []entryChange) string {
+	const maxShown = 3
+	var parts []string
+	for i, c := range changes {
+		if i == maxShown {
+			parts = append(parts, fmt.Sprintf("and %d more", len(changes)-maxShown))
+			break
+		}
+		parts = append(parts, describeChange(c))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeChange(c entryChange) string {
+	switch {
+	case c.Before == nil:
+		return "added " + c.After.Title
+	case c.After == nil:
+		return "deleted " + c.Before.Title
+	case c.Before.EndTime == nil && c.After.EndTime != nil:
+		return "stopped " + c.After.Title
+	case c.Before.EndTime != nil && c.After.EndTime == nil:
+		return "reopened " + c.After.Title
+	case c.Before.IsPaused() != c.After.IsPaused():
+		if c.After.IsPaused() {
+			return "paused " + c.After.Title
+		}
+		return "resumed " + c.After.Title
+	}
+	return "edited " + c.After.Title
+}
+
+// ShowHistory lists the most recent journal records, newest first, marking
+// the ones that are undone (and can be redone).
+func ShowHistory(limit int) error {
+	records, err := readJournal()
+	if err != nil {
+		return err
+	}
+	_, undone := undoStacks(records)
+	isUndone := make(map[int]bool, len(undone))
+	for _, rec := range undone {
+		isUndone[rec.Seq] = true
+	}
+
+	shown := records
+	if limit > 0 && len(shown) > limit {
+		shown = shown[len(shown)-limit:]
+	}
+
+	if jsonOutput {
+		out := make([]map[string]any, 0, len(shown))
+		for i := len(shown) - 1; i >= 0; i-- {
+			rec := shown[i]
+			out = append(out, map[string]any{"seq": rec.Seq, "time": rec.Time, "op": rec.Op,
+				"reverts": rec.Reverts, "undone": isUndone[rec.Seq], "changes": rec.Changes})
+		}
+		return printJSON(map[string]any{"history": out, "total": len(records)})
+	}
+
+	if len(records) == 0 {
+		textln("No operations recorded yet")
+		return nil
+	}
+
+	textf("%-5s %-17s %-14s %s\n", "SEQ", "TIME", "OP", "CHANGES")
+	textln(strings.Repeat("-", 80))
+	for i := len(shown) - 1; i >= 0; i-- {
+		rec := shown[i]
+		op := rec.Op
+		if rec.Reverts != 0 {
+			op = fmt.Sprintf("%s #%d", rec.Op, rec.Reverts)
+		}
+		line := fmt.Sprintf("%-5d %-17s %-14s %s", rec.Seq, formatDateTime(rec.Time), op, describeChanges(rec.Changes))
+		if isUndone[rec.Seq] {
+			line += " (undone)"
+		}
+		textln(line)
+	}
+	if limit > 0 && len(records) > limit {
+		textf("\nShowing %d of %d operations. Use -n <number> to show more.\n", limit, len(records))
+	}
+	return nil
+}
+
+// ShowJournalRecord prints the entries one operation changed, before and
+// after.
+func ShowJournalRecord(seq int) error {
+	records, err := readJournal()
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if rec.Seq != seq {
+			continue
+		}
+		if jsonOutput {
+			return printJSON(map[string]any{"record": rec})
+		}
+		textf("#%d %s at %s\n", rec.Seq, rec.Op, formatDateTimeSeconds(rec.Time))
+		for _, c := range rec.Changes {
+			textf("\n%s:\n", describeChange(c))
+			if c.Before != nil {
+				textf("  before: %s\n", describeJournalEntry(c.Before))
+			}
+			if c.After != nil {
+				textf("  after:  %s\n", describeJournalEntry(c.After))
+			}
+		}
+		return nil
+	}
+	return withCode(codeNotFound, fmt.Errorf("no operation #%d in the journal", seq))
+}
+
+// describeJournalEntry shows an entry's state on one line.
+func describeJournalEntry(e *TimeEntry) string {
+	end := "running"
+	if e.EndTime != nil {
+		end = formatDateTime(*e.EndTime)
+	}
+	s := fmt.Sprintf("%s%s [%s] %s - %s", e.Title, formatMeta(e), e.ID, formatDateTime(e.StartTime), end)
+	if e.Notes != "" {
+		s += fmt.Sprintf(" (notes: %q)", e.Notes)
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lockDepth counts nested lockData calls in this process, so a mutator that
+// calls another (continue starting a task) does not wait on its own lock.
+var lockDepth int
+
+// lockData takes an exclusive advisory lock on the data file for a
+// load-modify-save cycle, waiting for other timetrack processes (shell hooks,
+// editor plugins) to finish theirs. The lock lives on a separate .lock file
+// because SaveData replaces the data file itself, and is named without the
+// data file's extension so the JSON and SQLite stores share it. Hooks queued
+// while the lock was held run once it is released.
+func lockData() (unlock func(), err error) {
+	if lockDepth > 0 {
+		lockDepth++
+		return func() { lockDepth-- }, nil
+	}
+
+	path, err := getDataFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureDataDir(path); err != nil {
+		return nil, err
+	}
+	lockPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".lock"
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, withCode(codeStorage, fmt.Errorf("failed to open lock file: %w", err))
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, withCode(codeStorage, fmt.Errorf("failed to lock data file: %w", err))
+	}
+
+	lockDepth = 1
+	return func() {
+		lockDepth--
+		if lockDepth == 0 {
+			unlockFile(file)
+			file.Close()
+			runHooks()
+		}
+	}, nil
+}
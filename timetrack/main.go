@@ -2,46 +2,265 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// stringList is a flag.Value that collects every occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseFlags parses a command's flags. A bad flag is reported the way the
+// flag package does, or as a JSON usage error under --json (even when --json
+// comes after the bad flag).
+func parseFlags(fs *flag.FlagSet, args []string) {
+	var msg bytes.Buffer
+	fs.SetOutput(&msg)
+	err := fs.Parse(args)
+	fs.SetOutput(nil)
+	switch {
+	case err == flag.ErrHelp:
+		fmt.Fprint(os.Stderr, msg.String())
+		os.Exit(0)
+	case err != nil && (jsonOutput || slices.Contains(args, "--json") || slices.Contains(args, "-json")):
+		jsonOutput = true
+		exitWithError(codeUsage, err)
+	case err != nil:
+		fmt.Fprint(os.Stderr, msg.String())
+		os.Exit(2)
+	}
+}
+
+// addOutputFlags gives a command --json and --format text|json, plus
+// --format <template> when templates is set.
+func addOutputFlags(fs *flag.FlagSet, templates bool) {
+	fs.BoolVar(&jsonOutput, "json", jsonOutput, "print JSON instead of text")
+	if templates {
+		fs.Var(outputFormat{fs.Name(), true}, "format", "output format: text, json, a template such as '{{.Title}} {{.Duration}}', or the name of a "+fs.Name()+".<name>.tmpl in the config directory")
+	} else {
+		fs.Var(outputFormat{fs.Name(), false}, "format", "output format: text or json")
+	}
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		parseFlags(fs, args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// optionalValue is a flag that may be given bare (--week) or with a value
+// (--week=2026-W41).
+type optionalValue struct {
+	set   bool
+	value string
+}
+
+func (o *optionalValue) String() string {
+	return o.value
+}
+
+func (o *optionalValue) Set(value string) error {
+	o.set = true
+	if value != "true" {
+		o.value = value
+	}
+	return nil
+}
+
+func (o *optionalValue) IsBoolFlag() bool {
+	return true
+}
+
 func main() {
-	if len(os.Args) < 2 {
+	// Global flags come before the command
+	globalCmd := flag.NewFlagSet("timetrack", flag.ExitOnError)
+	globalCmd.Usage = printUsage
+	assumeYes := globalCmd.Bool("yes", os.Getenv("TIMETRACK_YES") != "", "answer prompts with the default (e.g. auto-stop forgotten timers at the cap)")
+	globalCmd.BoolVar(assumeYes, "y", *assumeYes, "shorthand for --yes")
+	globalCmd.StringVar(&dataFile, "file", "", "data file to use instead of the default ($TIMETRACK_FILE)")
+	globalCmd.StringVar(&dataProfile, "profile", "", "named profile with its own data file ($TIMETRACK_PROFILE)")
+	globalCmd.BoolVar(&jsonOutput, "json", false, "print JSON instead of text, and errors as JSON on stderr")
+	globalCmd.Parse(os.Args[1:])
+	cmdArgs := globalCmd.Args()
+
+	if len(cmdArgs) < 1 {
 		printUsage()
 		os.Exit(1)
 	}
 
+	// Settings from config.json. A broken config only blocks commands other
+	// than config itself, so it can still be repaired from the CLI.
+	config, configErr := LoadConfig()
+	if configErr == nil {
+		configErr = applyConfig(config)
+	}
+	if configErr != nil {
+		if cmdArgs[0] != "config" && cmdArgs[0] != "help" {
+			exitWithError(errorCode(configErr), configErr)
+		}
+		config = &Config{}
+	}
+	defaultListLimit := 10
+	if config.List.Limit != nil {
+		defaultListLimit = *config.List.Limit
+	}
+
 	// Define subcommand flag sets
-	startCmd := flag.NewFlagSet("start", flag.ExitOnError)
-	stopCmd := flag.NewFlagSet("stop", flag.ExitOnError)
-	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
-	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
-	listLimit := listCmd.Int("n", 10, "number of entries to show (0 for all)")
-	viewCmd := flag.NewFlagSet("view", flag.ExitOnError)
-	deleteCmd := flag.NewFlagSet("delete", flag.ExitOnError)
-
-	editCmd := flag.NewFlagSet("edit", flag.ExitOnError)
+	startCmd := flag.NewFlagSet("start", flag.ContinueOnError)
+	startProject := startCmd.String("project", "", "project for the entry")
+	var startTags stringList
+	startCmd.Var(&startTags, "tag", "tag for the entry (repeatable)")
+	startNoBill := startCmd.Bool("no-bill", false, "mark the entry as non-billable")
+	startFor := startCmd.Duration("for", 0, "timebox the entry, stopping it automatically after this long (e.g. 25m)")
+	startAt := startCmd.String("at", "", "start time if not now (e.g. 08:45, \"10 min ago\")")
+	stopCmd := flag.NewFlagSet("stop", flag.ContinueOnError)
+	stopAt := stopCmd.String("at", "", "stop time if not now (e.g. 17:30, \"10 min ago\")")
+	continueCmd := flag.NewFlagSet("continue", flag.ContinueOnError)
+	titlesCmd := flag.NewFlagSet("titles", flag.ContinueOnError)
+	titlesLimit := titlesCmd.Int("n", 0, "maximum number of titles to show (0 for all)")
+	pomodoroCmd := flag.NewFlagSet("pomodoro", flag.ContinueOnError)
+	pomodoroWork := pomodoroCmd.Duration("work", 25*time.Minute, "length of a work round")
+	pomodoroBreak := pomodoroCmd.Duration("break", 5*time.Minute, "length of a short break")
+	pomodoroLong := pomodoroCmd.Duration("long-break", 15*time.Minute, "length of a long break")
+	pomodoroEvery := pomodoroCmd.Int("long-every", 4, "take a long break after this many rounds")
+	pomodoroRounds := pomodoroCmd.Int("rounds", 4, "number of rounds (0 runs until interrupted)")
+	pomodoroProject := pomodoroCmd.String("project", "", "project for the work entries")
+	pauseCmd := flag.NewFlagSet("pause", flag.ContinueOnError)
+	resumeCmd := flag.NewFlagSet("resume", flag.ContinueOnError)
+	statusCmd := flag.NewFlagSet("status", flag.ContinueOnError)
+	tuiCmd := flag.NewFlagSet("tui", flag.ContinueOnError)
+	watchCmd := flag.NewFlagSet("watch", flag.ContinueOnError)
+	watchInterval := watchCmd.Duration("interval", time.Second, "how often to redraw")
+	undoCmd := flag.NewFlagSet("undo", flag.ContinueOnError)
+	redoCmd := flag.NewFlagSet("redo", flag.ContinueOnError)
+	historyCmd := flag.NewFlagSet("history", flag.ContinueOnError)
+	historyLimit := historyCmd.Int("n", 20, "number of operations to show (0 for all)")
+	statusShort := statusCmd.Bool("short", false, "print the running task on one line (empty when idle) for prompts and status bars")
+	listCmd := flag.NewFlagSet("list", flag.ContinueOnError)
+	listLimit := listCmd.Int("n", defaultListLimit, "number of entries to show (0 for all, default from list.limit in the config)")
+	listProject := listCmd.String("project", "", "only show entries for this project")
+	listTag := listCmd.String("tag", "", "only show entries with this tag")
+	listAutoStopped := listCmd.Bool("auto-stopped", false, "only show entries that were auto-stopped and need review")
+	viewCmd := flag.NewFlagSet("view", flag.ContinueOnError)
+	deleteCmd := flag.NewFlagSet("delete", flag.ContinueOnError)
+	restoreCmd := flag.NewFlagSet("restore-backup", flag.ContinueOnError)
+	migrateCmd := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	migrateTo := migrateCmd.String("to", "", "store to move the data to: sqlite or json")
+
+	editCmd := flag.NewFlagSet("edit", flag.ContinueOnError)
 	editTitle := editCmd.String("title", "", "new title for the entry")
-	editStart := editCmd.Int("start", 0, "adjust start time by minutes (negative = earlier)")
-	editEnd := editCmd.Int("end", 0, "adjust end time by minutes (negative = earlier)")
+	editStart := editCmd.String("start", "", "new start time, or minutes to adjust by (negative = earlier)")
+	editEnd := editCmd.String("end", "", "new end time, or minutes to adjust by (negative = earlier)")
+	editProject := editCmd.String("project", "", "new project for the entry (empty to clear)")
+	var editTags, editUntags stringList
+	editCmd.Var(&editTags, "tag", "add a tag to the entry (repeatable)")
+	editCmd.Var(&editUntags, "untag", "remove a tag from the entry (repeatable)")
+	editBillable := editCmd.Bool("billable", true, "whether the entry is billable (--billable=false to exclude it from invoices)")
+
+	noteCmd := flag.NewFlagSet("note", flag.ContinueOnError)
+
+	logCmd := flag.NewFlagSet("log", flag.ContinueOnError)
+	logFrom := logCmd.String("from", "", "start time (e.g. 09:30)")
+	logTo := logCmd.String("to", "", "end time (e.g. 10:15)")
+	logEnded := logCmd.String("ended", "", "end time, use with a duration")
+	logDate := logCmd.String("date", "today", "day of the entry (today, yesterday or YYYY-MM-DD)")
+	logProject := logCmd.String("project", "", "project for the entry")
+	var logTags stringList
+	logCmd.Var(&logTags, "tag", "tag for the entry (repeatable)")
+	logNoBill := logCmd.Bool("no-bill", false, "mark the entry as non-billable")
+
+	exportCmd := flag.NewFlagSet("export", flag.ContinueOnError)
+	exportFormat := exportCmd.String("format", "csv", "output format: csv, json, jsonl or ics")
+	exportFrom := exportCmd.String("from", "", "only entries starting at or after this time")
+	exportTo := exportCmd.String("to", "", "only entries starting before this time (a bare day is inclusive)")
+	exportOutput := exportCmd.String("o", "", "write to this file instead of stdout")
 
-	noteCmd := flag.NewFlagSet("note", flag.ExitOnError)
+	importCmd := flag.NewFlagSet("import", flag.ContinueOnError)
+	importFrom := importCmd.String("from", "", "source tracker: toggl, clockify or timewarrior")
+	importDryRun := importCmd.Bool("dry-run", false, "report what would be imported without saving")
 
-	summaryCmd := flag.NewFlagSet("summary", flag.ExitOnError)
+	invoiceCmd := flag.NewFlagSet("invoice", flag.ContinueOnError)
+	invoiceClient := invoiceCmd.String("client", "", "client (or project) to invoice")
+	invoiceMonth := invoiceCmd.String("month", "", "month to invoice, YYYY-MM (default: this month)")
+	invoiceFrom := invoiceCmd.String("from", "", "start of a custom period")
+	invoiceTo := invoiceCmd.String("to", "", "end of a custom period (a bare day is inclusive)")
+	invoiceFormat := invoiceCmd.String("format", "md", "output format: html, md or json")
+	invoiceOutput := invoiceCmd.String("o", "", "write to this file instead of stdout")
+
+	summaryCmd := flag.NewFlagSet("summary", flag.ContinueOnError)
 	summaryToday := summaryCmd.Bool("today", false, "show today's summary")
-	summaryWeek := summaryCmd.Bool("week", false, "show this week's summary")
+	summaryYesterday := summaryCmd.Bool("yesterday", false, "show yesterday's summary")
+	var summaryWeek, summaryMonth, summaryYear optionalValue
+	summaryCmd.Var(&summaryWeek, "week", "show this week's summary, or a given week (2026-W41)")
+	summaryLastWeek := summaryCmd.Bool("last-week", false, "show last week's summary")
+	summaryCmd.Var(&summaryMonth, "month", "show this month's summary, or a given month (2026-09)")
+	summaryLastMonth := summaryCmd.Bool("last-month", false, "show last month's summary")
+	summaryCmd.Var(&summaryYear, "year", "show this year's summary, or a given year (2025)")
 	summaryLast := summaryCmd.Bool("last", false, "show last working day's summary")
+	summaryFrom := summaryCmd.String("from", "", "start of a custom range")
+	summaryTo := summaryCmd.String("to", "", "end of a custom range (a bare day is inclusive)")
+	summaryWeekStart := summaryCmd.String("week-start", "", "first day of the week (default $TIMETRACK_WEEK_START, week_start in the config, or monday)")
+	summaryBy := summaryCmd.String("by", "title", "group by project, tag or title")
+	summarySort := summaryCmd.String("sort", "duration", "order groups by duration, title, first or last")
+	summaryTop := summaryCmd.Int("top", 0, "show only the top N groups and sum the rest as other")
+	summaryDecimal := summaryCmd.Bool("decimal", false, "show decimal hours (e.g. 1.50h) for billing")
+	summaryGrid := summaryCmd.Bool("grid", false, "show a timesheet grid with a column per day")
+
+	for _, fs := range []*flag.FlagSet{startCmd, stopCmd, continueCmd, titlesCmd, pomodoroCmd, pauseCmd, resumeCmd,
+		deleteCmd, restoreCmd, migrateCmd, editCmd, noteCmd, logCmd, importCmd, undoCmd, redoCmd, historyCmd} {
+		addOutputFlags(fs, false)
+	}
+	for _, fs := range []*flag.FlagSet{statusCmd, watchCmd, listCmd, viewCmd, summaryCmd} {
+		addOutputFlags(fs, true)
+	}
+	// export and invoice have formats of their own, json among them
+	exportCmd.BoolVar(&jsonOutput, "json", jsonOutput, "same as --format json")
+	invoiceCmd.BoolVar(&jsonOutput, "json", jsonOutput, "same as --format json")
 
 	var err error
-	command := os.Args[1]
+	command := cmdArgs[0]
+
+	// restore-backup must work even when the data file is corrupt, and
+	// status --short must stay fast enough for a shell prompt
+	shortStatus := command == "status" && (slices.Contains(cmdArgs[1:], "--short") || slices.Contains(cmdArgs[1:], "-short"))
+	journalOp = "auto-stop"
+	if command != "help" && command != "restore-backup" && command != "config" && !shortStatus {
+		if err := stopExpiredTimebox(); err != nil {
+			exitWithError(errorCode(err), err)
+		}
+		if err := capForgottenTimer(*assumeYes); err != nil {
+			exitWithError(errorCode(err), err)
+		}
+	}
+
+	journalOp = command
 
 	switch command {
 	case "start":
-		startCmd.Parse(os.Args[2:])
+		parseFlags(startCmd, cmdArgs[1:])
 		args := startCmd.Args()
 		var title string
 		if len(args) > 0 {
@@ -56,142 +275,580 @@ func main() {
 			}
 		}
 		if title == "" {
-			fmt.Println("Error: missing task title")
-			fmt.Println("Usage: timetrack start <title>")
-			os.Exit(1)
+			usageError("missing task title", "Usage: timetrack start <title>")
 		}
-		err = StartTask(title)
+		opts := StartOptions{Project: *startProject, Tags: startTags, For: *startFor, NonBillable: *startNoBill}
+		if *startAt != "" {
+			at, parseErr := parseTimeExpr(*startAt, time.Now())
+			if parseErr != nil {
+				usageError(parseErr.Error())
+			}
+			opts.At = at
+		}
+		err = StartTask(title, opts)
 
 	case "stop":
-		stopCmd.Parse(os.Args[2:])
-		err = StopTask()
+		parseFlags(stopCmd, cmdArgs[1:])
+		var at time.Time
+		if *stopAt != "" {
+			var parseErr error
+			if at, parseErr = parseTimeExpr(*stopAt, time.Now()); parseErr != nil {
+				usageError(parseErr.Error())
+			}
+		}
+		err = StopTask(at)
+
+	case "continue", "restart":
+		parseFlags(continueCmd, cmdArgs[1:])
+		ref := ""
+		if args := continueCmd.Args(); len(args) > 0 {
+			ref = args[0]
+		}
+		err = ContinueTask(ref)
+
+	case "titles":
+		parseFlags(titlesCmd, cmdArgs[1:])
+		err = ListTitles(strings.Join(titlesCmd.Args(), " "), *titlesLimit)
+
+	case "pomodoro":
+		parseFlags(pomodoroCmd, cmdArgs[1:])
+		title := strings.Join(pomodoroCmd.Args(), " ")
+		if title == "" {
+			title = "Pomodoro"
+		}
+		err = RunPomodoro(title, *pomodoroProject, PomodoroOptions{
+			Work:      *pomodoroWork,
+			Break:     *pomodoroBreak,
+			LongBreak: *pomodoroLong,
+			LongEvery: *pomodoroEvery,
+			Rounds:    *pomodoroRounds,
+		})
+
+	case "pause":
+		parseFlags(pauseCmd, cmdArgs[1:])
+		err = PauseTask()
+
+	case "resume":
+		parseFlags(resumeCmd, cmdArgs[1:])
+		err = ResumeTask()
 
 	case "status":
-		statusCmd.Parse(os.Args[2:])
-		err = Status()
+		parseFlags(statusCmd, cmdArgs[1:])
+		if *statusShort {
+			if outputTemplate != nil {
+				usageError("--short cannot be combined with a --format template", "Usage: timetrack status [--short] [--json | --format <template>]")
+			}
+			err = ShortStatus()
+		} else {
+			err = Status()
+		}
+
+	case "watch":
+		parseFlags(watchCmd, cmdArgs[1:])
+		if *watchInterval <= 0 {
+			usageError("--interval must be positive", "Usage: timetrack watch [--interval 1s]")
+		}
+		err = Watch(*watchInterval)
+
+	case "undo":
+		parseFlags(undoCmd, cmdArgs[1:])
+		err = Undo()
+
+	case "redo":
+		parseFlags(redoCmd, cmdArgs[1:])
+		err = Redo()
+
+	case "history":
+		args := parseInterspersed(historyCmd, cmdArgs[1:])
+		if len(args) == 0 {
+			err = ShowHistory(*historyLimit)
+			break
+		}
+		seq, convErr := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if convErr != nil {
+			usageError(fmt.Sprintf("invalid operation number: %s", args[0]), "Usage: timetrack history [-n <limit>] [<seq>]")
+		}
+		err = ShowJournalRecord(seq)
+
+	case "tui":
+		parseFlags(tuiCmd, cmdArgs[1:])
+		if !plainText() {
+			usageError("tui has no JSON or template output", "Usage: timetrack tui")
+		}
+		err = RunTUI()
 
 	case "list":
-		listCmd.Parse(os.Args[2:])
-		err = ListTasks(*listLimit)
+		parseFlags(listCmd, cmdArgs[1:])
+		err = ListTasks(*listLimit, EntryFilter{Project: *listProject, Tag: *listTag, AutoStopped: *listAutoStopped})
 
 	case "view":
-		viewCmd.Parse(os.Args[2:])
-		args := viewCmd.Args()
+		args := parseInterspersed(viewCmd, cmdArgs[1:])
 		if len(args) == 0 {
-			fmt.Println("Error: missing entry index")
-			fmt.Println("Usage: timetrack view <index>")
-			os.Exit(1)
-		}
-		index, parseErr := strconv.Atoi(args[0])
-		if parseErr != nil {
-			fmt.Println("Error: index must be a number")
-			os.Exit(1)
+			usageError("missing entry index or ID", "Usage: timetrack view <index|id>")
 		}
-		err = ViewTask(index)
+		err = ViewTask(args[0])
 
 	case "delete":
-		deleteCmd.Parse(os.Args[2:])
+		parseFlags(deleteCmd, cmdArgs[1:])
 		args := deleteCmd.Args()
 		if len(args) == 0 {
-			fmt.Println("Error: missing entry index")
-			fmt.Println("Usage: timetrack delete <index>")
-			os.Exit(1)
-		}
-		index, parseErr := strconv.Atoi(args[0])
-		if parseErr != nil {
-			fmt.Println("Error: index must be a number")
-			os.Exit(1)
+			usageError("missing entry index or ID", "Usage: timetrack delete <index|id>")
 		}
-		err = DeleteTask(index)
+		err = DeleteTask(args[0])
 
 	case "edit":
-		editCmd.Parse(os.Args[2:])
+		parseFlags(editCmd, cmdArgs[1:])
 		args := editCmd.Args()
-		if *editTitle == "" && *editStart == 0 && *editEnd == 0 {
-			fmt.Println("Error: must specify --title, --start, or --end")
-			fmt.Println("Usage: timetrack edit [--title \"new title\"] [--start <mins>] [--end <mins>] <index>")
-			os.Exit(1)
+		opts := EditOptions{
+			Title:  *editTitle,
+			Tags:   editTags,
+			Untags: editUntags,
+			Start:  *editStart,
+			End:    *editEnd,
 		}
-		if len(args) == 0 {
-			fmt.Println("Error: missing entry index")
-			fmt.Println("Usage: timetrack edit [--title \"new title\"] [--start <mins>] [--end <mins>] <index>")
-			os.Exit(1)
+		editCmd.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "project":
+				opts.Project = editProject
+			case "billable":
+				opts.Billable = editBillable
+			}
+		})
+		if opts.Title == "" && opts.Project == nil && len(opts.Tags) == 0 && len(opts.Untags) == 0 &&
+			opts.Start == "" && opts.End == "" && opts.Billable == nil {
+			usageError("must specify --title, --project, --tag, --untag, --start, --end, or --billable",
+				"Usage: timetrack edit [--title \"new title\"] [--start <time|mins>] [--end <time|mins>] <index|id>")
 		}
-		index, parseErr := strconv.Atoi(args[0])
-		if parseErr != nil {
-			fmt.Println("Error: index must be a number")
-			os.Exit(1)
+		if len(args) == 0 {
+			usageError("missing entry index or ID",
+				"Usage: timetrack edit [--title \"new title\"] [--start <time|mins>] [--end <time|mins>] <index|id>")
 		}
-		err = EditTask(index, *editTitle, *editStart, *editEnd)
+		err = EditTask(args[0], opts)
 
 	case "note":
-		noteCmd.Parse(os.Args[2:])
+		parseFlags(noteCmd, cmdArgs[1:])
 		args := noteCmd.Args()
 		if len(args) < 2 {
-			fmt.Println("Error: missing index and/or note text")
-			fmt.Println("Usage: timetrack note <index> \"note text\"")
-			os.Exit(1)
-		}
-		index, parseErr := strconv.Atoi(args[0])
-		if parseErr != nil {
-			fmt.Println("Error: index must be a number")
-			os.Exit(1)
+			usageError("missing entry and/or note text", "Usage: timetrack note <index|id> \"note text\"")
 		}
 		noteText := strings.Join(args[1:], " ")
-		err = NoteTask(index, noteText)
+		err = NoteTask(args[0], noteText)
+
+	case "log":
+		args := parseInterspersed(logCmd, cmdArgs[1:])
+		start, end, logErr := logTimes(args, *logFrom, *logTo, *logEnded, *logDate)
+		if logErr != nil {
+			usageError(logErr.Error(),
+				"Usage: timetrack log <title> --from <HH:MM> --to <HH:MM> [--date <day>]",
+				"       timetrack log <title> <duration> [--ended <HH:MM>] [--date <day>]")
+		}
+		if len(args) > 1 {
+			if _, durErr := time.ParseDuration(args[len(args)-1]); durErr == nil {
+				args = args[:len(args)-1]
+			}
+		}
+		title := strings.Join(args, " ")
+		if title == "" {
+			usageError("missing task title", "Usage: timetrack log <title> --from <HH:MM> --to <HH:MM> [--date <day>]")
+		}
+		err = LogTask(title, start, end, StartOptions{Project: *logProject, Tags: logTags, NonBillable: *logNoBill})
+
+	case "export":
+		parseFlags(exportCmd, cmdArgs[1:])
+		from, to, rangeErr := parseRange(*exportFrom, *exportTo)
+		if rangeErr != nil {
+			usageError(rangeErr.Error(), "Usage: timetrack export [--format csv|json|jsonl|ics] [--from <time>] [--to <time>] [-o <file>]")
+		}
+		format := *exportFormat
+		if jsonOutput && !flagGiven(exportCmd, "format") {
+			format = "json"
+		}
+		jsonOutput = format == "json"
+		err = ExportEntries(format, from, to, *exportOutput)
+
+	case "import":
+		args := parseInterspersed(importCmd, cmdArgs[1:])
+		if *importFrom == "" || len(args) != 1 {
+			usageError("missing source or file", "Usage: timetrack import --from toggl|clockify|timewarrior [--dry-run] <file>")
+		}
+		err = ImportEntries(*importFrom, args[0], *importDryRun)
+
+	case "invoice":
+		parseFlags(invoiceCmd, cmdArgs[1:])
+		if *invoiceClient == "" {
+			usageError("missing client", "Usage: timetrack invoice --client <name> [--month YYYY-MM | --from <time> --to <time>] [--format html|md|json] [-o <file>]")
+		}
+		var from, to time.Time
+		var period string
+		var rangeErr error
+		if *invoiceFrom == "" && *invoiceTo == "" {
+			from, to, period, rangeErr = periodRange("month", *invoiceMonth, time.Now(), time.Monday)
+		} else if *invoiceFrom == "" || *invoiceTo == "" {
+			rangeErr = fmt.Errorf("--from and --to must be used together")
+		} else {
+			from, to, rangeErr = parseRange(*invoiceFrom, *invoiceTo)
+			period = "Custom period"
+		}
+		if rangeErr != nil {
+			usageError(rangeErr.Error())
+		}
+		format := *invoiceFormat
+		if jsonOutput && !flagGiven(invoiceCmd, "format") {
+			format = "json"
+		}
+		jsonOutput = format == "json"
+		err = InvoiceClient(*invoiceClient, from, to, period, format, *invoiceOutput)
 
 	case "summary":
-		summaryCmd.Parse(os.Args[2:])
-		filter := ""
-		if *summaryToday {
-			filter = "today"
-		} else if *summaryWeek {
-			filter = "week"
-		} else if *summaryLast {
-			filter = "last"
+		summaryArgs := parseInterspersed(summaryCmd, cmdArgs[1:])
+		opts := SummaryOptions{
+			GroupBy:   *summaryBy,
+			Sort:      *summarySort,
+			Top:       *summaryTop,
+			Decimal:   *summaryDecimal,
+			Grid:      *summaryGrid,
+			WeekStart: defaultWeekStart(),
+		}
+		if *summaryWeekStart != "" {
+			day, parseErr := parseWeekday(*summaryWeekStart)
+			if parseErr != nil {
+				usageError(parseErr.Error())
+			}
+			opts.WeekStart = day
+		}
+
+		var periodFlag *optionalValue
+		switch {
+		case *summaryToday:
+			opts.Period = "today"
+		case *summaryYesterday:
+			opts.Period = "yesterday"
+		case summaryWeek.set:
+			opts.Period, periodFlag = "week", &summaryWeek
+		case *summaryLastWeek:
+			opts.Period = "last-week"
+		case summaryMonth.set:
+			opts.Period, periodFlag = "month", &summaryMonth
+		case *summaryLastMonth:
+			opts.Period = "last-month"
+		case summaryYear.set:
+			opts.Period, periodFlag = "year", &summaryYear
+		case *summaryLast:
+			opts.Period = "last"
+		}
+		if periodFlag != nil {
+			// Allow "--week 2026-W41" as well as "--week=2026-W41"
+			if periodFlag.value == "" && len(summaryArgs) > 0 {
+				periodFlag.value = summaryArgs[0]
+			}
+			opts.PeriodValue = periodFlag.value
+		}
+
+		var rangeErr error
+		opts.From, opts.To, rangeErr = parseRange(*summaryFrom, *summaryTo)
+		if rangeErr != nil {
+			usageError(rangeErr.Error())
+		}
+		err = Summary(opts)
+
+	case "restore-backup":
+		parseFlags(restoreCmd, cmdArgs[1:])
+		args := restoreCmd.Args()
+		if len(args) == 0 {
+			err = ShowBackups()
+		} else {
+			err = RestoreBackup(args[0])
 		}
-		err = Summary(filter)
+
+	case "config":
+		var args []string
+		for _, arg := range cmdArgs[1:] {
+			if arg == "--json" || arg == "-json" {
+				jsonOutput = true
+			} else {
+				args = append(args, arg)
+			}
+		}
+		sub := "list"
+		if len(args) > 0 {
+			sub, args = args[0], args[1:]
+		}
+		switch {
+		case sub == "list" && len(args) == 0:
+			err = ConfigList()
+		case sub == "get" && len(args) == 1:
+			err = ConfigGet(args[0])
+		case sub == "set" && len(args) >= 2:
+			err = ConfigSet(args[0], strings.Join(args[1:], " "))
+		case sub == "unset" && len(args) == 1:
+			err = ConfigUnset(args[0])
+		default:
+			usageError("invalid config command", "Usage: timetrack config [list | get <key> | set <key> <value> | unset <key>]")
+		}
+
+	case "migrate":
+		parseFlags(migrateCmd, cmdArgs[1:])
+		if *migrateTo == "" {
+			usageError("missing target store", "Usage: timetrack migrate --to sqlite|json")
+		}
+		err = MigrateStore(*migrateTo)
 
 	case "help", "--help", "-h":
 		printUsage()
 
 	default:
+		if jsonOutput {
+			usageError(fmt.Sprintf("unknown command: %s", command))
+		}
 		fmt.Printf("Unknown command: %s\n\n", command)
 		printUsage()
 		os.Exit(1)
 	}
 
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exitWithError(errorCode(err), err)
+	}
+}
+
+// logTimes works out the span of a retroactive entry from the log flags and
+// an optional trailing duration argument such as "1h30m".
+func logTimes(args []string, from, to, ended, date string) (time.Time, time.Time, error) {
+	var start, end time.Time
+
+	now := time.Now()
+	day, err := parseDate(date, now)
+	if err != nil {
+		return start, end, err
+	}
+
+	var duration time.Duration
+	if len(args) > 1 {
+		duration, _ = time.ParseDuration(args[len(args)-1])
+	}
+
+	if to != "" && ended != "" {
+		return start, end, fmt.Errorf("use either --to or --ended, not both")
+	}
+	if ended != "" {
+		to = ended
+	}
+
+	if from != "" {
+		if start, err = parseTimeExprOn(from, day, now); err != nil {
+			return start, end, err
+		}
+	}
+	if to != "" {
+		if end, err = parseTimeExprOn(to, day, now); err != nil {
+			return start, end, err
+		}
+	}
+
+	switch {
+	case from != "" && to != "":
+		if duration != 0 {
+			return start, end, fmt.Errorf("give a duration or both --from and --to, not all three")
+		}
+	case from != "" && duration != 0:
+		end = start.Add(duration)
+	case to != "" && duration != 0:
+		start = end.Add(-duration)
+	case from == "" && to == "" && duration != 0:
+		end = now.Truncate(time.Minute)
+		start = end.Add(-duration)
+	default:
+		return start, end, fmt.Errorf("need --from and --to, or a duration")
+	}
+
+	return start, end, nil
+}
+
+// flagGiven reports whether the named flag was set on the command line.
+func flagGiven(fs *flag.FlagSet, name string) bool {
+	given := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// parseRange parses optional --from/--to flag values into range bounds,
+// leaving a bound zero (open) when its flag is empty.
+func parseRange(fromExpr, toExpr string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	now := time.Now()
+	if fromExpr != "" {
+		if from, err = parseTimeExpr(fromExpr, now); err != nil {
+			return from, to, err
+		}
+	}
+	if toExpr != "" {
+		if to, err = parseRangeEnd(toExpr, now); err != nil {
+			return from, to, err
+		}
 	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return from, to, fmt.Errorf("--to must be after --from")
+	}
+	return from, to, nil
 }
 
 func printUsage() {
 	fmt.Println(`timetrack - Simple time tracking CLI
 
 Usage:
-  timetrack <command> [arguments]
+  timetrack [--yes] [--json] [--file <path> | --profile <name>] <command> [arguments]
+
+Global flags:
+  --yes, -y                  Answer prompts with the default, e.g. stop a
+                             forgotten timer at its cap ($TIMETRACK_YES)
+  --file <path>              Use this data file ($TIMETRACK_FILE); a .db file
+                             is a SQLite store
+  --profile <name>           Use a separate data file for this profile, e.g.
+                             one per client ($TIMETRACK_PROFILE)
+  --json                     Print JSON instead of text (also accepted after
+                             any command, as is --format json)
 
 Commands:
-  start <title>              Start a new task (auto-stops current task)
-  stop                       Stop the current running task
-  status                     Show the current running task
-  list [-n <limit>]          List time entries (default: 10, most recent first)
-  view <index>               View full details of an entry
-  delete <index>             Delete an entry by index
-  edit [--title <title>] [--start <mins>] [--end <mins>] <index>
-                           Edit an entry (--start -30 = started 30 mins earlier)
-  note <index> <text>        Add a note to an entry (appends if note exists)
-  summary [--today|--week|--last]
-                             Show time summary (--last = last day with entries)
+  start [--project <p>] [--tag <t>]... [--at <time>] [--for <duration>] [--no-bill] <title>
+                             Start a new task (auto-stops current task);
+                             --for 25m stops it automatically at the planned end
+  stop [--at <time>]         Stop the current running task
+  continue [<entry>]         Start again on an earlier entry's title, project and
+                             tags (default: the most recently stopped entry)
+                             Alias: restart
+  titles [-n <limit>] [<query>]
+                             List earlier titles matching query, best first
+                             (for shell completion of start)
+  pomodoro [--work 25m] [--break 5m] [--rounds 4] [<title>]
+                             Alternate timeboxed work and break entries
+  pause                      Pause the running task (e.g. for lunch)
+  resume                     Resume the paused task
+  status [--short]           Show the current running task; --short prints one
+                             line ("⏱ Fix login 1h12m", empty when idle) from a
+                             small state file, for shell prompts and tmux
+  watch [--interval 1s]      Keep the running task, its elapsed time and today's
+                             progress on screen, following starts and stops
+                             made in other terminals (Ctrl-C to quit)
+  tui                        Full-screen interface: browse and filter entries,
+                             start, stop, edit, annotate and delete them
+  list [-n <limit>] [--project <p>] [--tag <t>] [--auto-stopped]
+                             List time entries (default: 10, most recent first);
+                             auto-stopped entries are marked with *
+  view <entry>               View full details of an entry
+  delete <entry>             Delete an entry
+  edit [--title <title>] [--start <time|mins>] [--end <time|mins>] <entry>
+                           Edit an entry (--start -30 = started 30 mins earlier,
+                           --start 09:05 = started at 09:05 that day)
+                           Also --project <p>, --tag <t>, --untag <t>,
+                           --billable=false
+  note <entry> <text>        Add a note to an entry (appends if note exists)
+  log <title> --from <time> --to <time> [--date <day>]
+  log <title> <duration> [--ended <time>] [--date <day>]
+                             Record a finished entry after the fact
+  export [--format csv|json|jsonl|ics] [--from <time>] [--to <time>] [-o <file>]
+                             Export entries for spreadsheets or calendars
+  import --from toggl|clockify|timewarrior [--dry-run] <file>
+                             Import entries exported from another tracker
+  undo                       Revert the last change (start, stop, edit, delete,
+                             note, import, ...); undo again to go further back
+  redo                       Re-apply the last undone change
+  history [-n <limit>] [<seq>]
+                             List recorded changes, or show one in detail
+  restore-backup [<backup>]  List the backups kept of the data file, or restore
+                             one (by IDX, 0 = newest) after a bad edit or crash
+  migrate --to sqlite|json   Move the data to another store; SQLite stays fast
+                             with years of entries
+  invoice --client <name> [--month YYYY-MM] [--format html|md|json] [-o <file>]
+                             Render an invoice of billable time (rates, rounding
+                             and clients come from ~/.config/timetrack/config.json)
+  summary [<period>] [--from <time>] [--to <time>] [--by project|tag|title]
+          [--sort duration|title|first|last] [--top <n>] [--decimal] [--grid]
+                             Show time summary for a period:
+                             --today, --yesterday, --last (last day with entries),
+                             --week [2026-W41], --last-week, --month [2026-09],
+                             --last-month, --year [2025]
+                             (--week-start sunday or $TIMETRACK_WEEK_START to
+                             change the first day of the week)
+                             --grid shows a timesheet with a column per day
+
+A <time> is HH:MM (on the entry's day, or today), 3pm, YYYY-MM-DD HH:MM,
+"yesterday 17:00", a weekday name such as "monday 09:00", or "10 min ago".
+
+An <entry> is the IDX shown by list or its ID (a unique prefix is enough).
+A number of 4+ digits that is also the start of an ID is refused; use @IDX
+for the index.
+IDs never change, so prefer them in scripts.
+
+Data is kept in $XDG_DATA_HOME/timetrack (~/.local/share/timetrack), with
+profiles under profiles/ there. An existing ~/.timetrack.json is still used.
+
+A timer left running past the auto_stop limits in the config (e.g.
+{"auto_stop": {"max_running": "10h", "end_of_day": "19:00"}}) is stopped at
+the cap (or the last activity, when asked) on the next command and flagged
+for review.
+
+With --json every command prints one JSON document: list gives {"entries",
+"total"}, status {"running", "today_seconds", ...}, summary the totals per
+group and day, and so on. Errors go to stderr as {"error", "code"} with a
+non-zero exit; the code is one of usage, not_found, conflict, storage or
+error.
+
+list, status, watch, view and summary also take --format <template>, a Go
+text/template such as '{{.Title}} {{.Duration}}' (run per entry for list),
+or the name of a template file, e.g. --format standup for summary reads
+summary.standup.tmpl in ~/.config/timetrack. Templates can use .Title,
+.Project, .Tags, .Start, .End, .Duration, .Running (status adds .Today and
+.DailyTarget; summary has .Total, .Groups and .Days) and the functions date,
+clock, datetime, hours, join, pad, upper and lower.
+
+Titles may carry inline metadata: "@name" sets the project and "+name" adds
+a tag, e.g. timetrack start "Fix login +backend @acme".
 
 Examples:
   timetrack start "Working on feature X"
+  todo next | timetrack start --project acme
   timetrack stop
+  timetrack continue              # pick up the last task again
+  timetrack start "$(timetrack titles -n 1 login)"
   timetrack list
   timetrack list -n 20    # Show 20 entries
   timetrack list -n 0     # Show all entries
   timetrack note 0 "Fixed the login bug"
-  timetrack summary --today`)
+  timetrack view 3fa9             # by ID prefix
+  timetrack log "Meeting" --from 09:30 --to 10:15 --date yesterday
+  timetrack log "Review" 1h30m --ended 14:00
+  timetrack start --at 08:45 "Standup"
+  timetrack stop --at "10 minutes ago"
+  timetrack start --for 25m "Write tests"
+  timetrack pomodoro --project acme "Write tests"
+  timetrack edit --start 09:05 --end "2026-10-16 17:30" 0
+  timetrack list --project acme
+  timetrack --yes status          # cap a forgotten timer without asking
+  timetrack list --auto-stopped
+  timetrack export --format ics --from 2026-09-01 -o september.ics
+  timetrack import --from toggl --dry-run toggl-report.csv
+  timew export | timetrack import --from timewarrior -
+  timetrack delete 3 && timetrack undo
+  timetrack history 42            # what did operation 42 change?
+  timetrack restore-backup 0      # undo the last save
+  timetrack migrate --to sqlite
+  timetrack --profile client-a start "Design review"
+  timetrack --profile client-a summary --month
+  timetrack invoice --client acme --month 2026-09 --format html -o acme-2026-09.html
+  timetrack summary --today
+  timetrack status --json         # for status bars
+  PS1='$(timetrack status --short) \$ '
+  timetrack status --format '{{if .Running}}{{.Title}} {{.Duration}}{{end}}'
+  timetrack list --format '{{.ID}} {{hours .Duration}} {{.Title}}'
+  timetrack summary --yesterday --format standup
+  timetrack --json summary --week --by project
+  timetrack summary --week --by project
+  timetrack summary --week 2026-W41
+  timetrack summary --month --by project --top 5 --decimal
+  timetrack summary --week --grid --by project
+  timetrack summary --from 2026-09-01 --to 2026-09-15`)
 }
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -69,12 +75,15 @@ func setupTestStorage(t *testing.T) (cleanup func()) {
 
 	tmpDir := t.TempDir()
 
-	// Override the home directory for tests
-	origHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
+	// Point the data file and config at the temp dir, so a developer's own
+	// data and config.json are never touched or picked up
+	origFile, origConfig := os.Getenv("TIMETRACK_FILE"), os.Getenv("XDG_CONFIG_HOME")
+	os.Setenv("TIMETRACK_FILE", filepath.Join(tmpDir, "timetrack.json"))
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
 
 	return func() {
-		os.Setenv("HOME", origHome)
+		os.Setenv("TIMETRACK_FILE", origFile)
+		os.Setenv("XDG_CONFIG_HOME", origConfig)
 	}
 }
 
@@ -147,3 +156,1410 @@ func TestFindRunningTask(t *testing.T) {
 		t.Error("Expected no running task")
 	}
 }
+
+func TestParseInlineMeta(t *testing.T) {
+	tests := []struct {
+		input   string
+		title   string
+		project string
+		tags    []string
+	}{
+		{"Fix login +backend @acme", "Fix login", "acme", []string{"backend"}},
+		{"Plain  title", "Plain  title", "", nil},
+		{"Review +ops +ops +urgent", "Review", "", []string{"ops", "urgent"}},
+		{"Email me@host + more", "Email me@host + more", "", nil},
+	}
+
+	for _, tt := range tests {
+		title, project, tags := parseInlineMeta(tt.input)
+		if title != tt.title || project != tt.project || strings.Join(tags, ",") != strings.Join(tt.tags, ",") {
+			t.Errorf("parseInlineMeta(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.input, title, project, tags, tt.title, tt.project, tt.tags)
+		}
+	}
+}
+
+func TestStartTaskWithMeta(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	if err := StartTask("Fix login +backend @acme", StartOptions{Tags: []string{"bug"}}); err != nil {
+		t.Fatalf("StartTask() error = %v", err)
+	}
+
+	data, err := LoadData()
+	if err != nil {
+		t.Fatalf("LoadData() error = %v", err)
+	}
+	entry := data.Entries[0]
+	if entry.Title != "Fix login" || entry.Project != "acme" {
+		t.Errorf("Expected title 'Fix login' in project 'acme', got %q in %q", entry.Title, entry.Project)
+	}
+	if !entry.HasTag("backend") || !entry.HasTag("bug") {
+		t.Errorf("Expected tags backend and bug, got %v", entry.Tags)
+	}
+}
+
+func TestResolveEntry(t *testing.T) {
+	now := time.Now()
+	data := &TimeData{
+		Entries: []TimeEntry{
+			{ID: "a1b2c3d4", Title: "Oldest", StartTime: now.Add(-2 * time.Hour)},
+			{ID: "a1ffffff", Title: "Newest", StartTime: now},
+			{ID: "12345678", Title: "Middle", StartTime: now.Add(-1 * time.Hour)},
+			{ID: "5f411438", Title: "Older", StartTime: now.Add(-3 * time.Hour)},
+			{ID: "b0000000", Title: "Oldest of all", StartTime: now.Add(-4 * time.Hour)},
+			{ID: "c0000000", Title: "Ancient", StartTime: now.Add(-5 * time.Hour)},
+		},
+	}
+
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{"0", 1, false},        // index, most recent first
+		{"2", 0, false},        // index
+		{"a1b2", 0, false},     // unique ID prefix
+		{"12345678", 2, false}, // full numeric ID beats index
+		{"a1", -1, true},       // ambiguous prefix
+		{"zz", -1, true},       // no match
+		{"7", -1, true},        // index out of range
+		{"5", 5, false},        // short numbers are indices, even if 5f411438 starts with 5
+		{"5f", 3, false},       // ID prefix
+		{"@5", 5, false},       // explicit index
+		{"@9", -1, true},       // explicit index out of range
+		{"1", 2, false},        // index 1, not 12345678
+		{"1234", 2, false},     // too long for an index, so an ID prefix
+	}
+
+	for _, tt := range tests {
+		got, err := resolveEntry(data, tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveEntry(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveEntry(%q) = %d, want %d", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestResolveEntryLegacyIDs(t *testing.T) {
+	// IDs from before generateID started them with a letter often begin
+	// with a digit; plain indices must keep working on such data
+	now := time.Now()
+	data := &TimeData{}
+	for i := 0; i < 1300; i++ {
+		data.Entries = append(data.Entries, TimeEntry{ID: fmt.Sprintf("%d%07x", i%10, i), Title: fmt.Sprint(i), StartTime: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	data.Entries[7].ID = "1234abcd"
+
+	for index := 0; index < 5; index++ {
+		got, err := resolveEntry(data, fmt.Sprint(index))
+		if err != nil || got != index {
+			t.Errorf("resolveEntry(%d) = %d, %v, want the index", index, got, err)
+		}
+	}
+
+	_, err := resolveEntry(data, "1234")
+	if errorCode(err) != codeUsage {
+		t.Errorf("Expected 1234 to be ambiguous between index and 1234abcd, got %v", err)
+	}
+	if got, err := resolveEntry(data, "@1234"); err != nil || got != 1234 {
+		t.Errorf("resolveEntry(@1234) = %d, %v, want 1234", got, err)
+	}
+	if got, err := resolveEntry(data, "1234a"); err != nil || got != 7 {
+		t.Errorf("resolveEntry(1234a) = %d, %v, want 7", got, err)
+	}
+}
+
+func TestLogTask(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	day := startOfDay(time.Now()).AddDate(0, 0, -1)
+	at := func(hour, min int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+	}
+
+	if err := LogTask("Meeting", at(9, 30), at(10, 15), StartOptions{}); err != nil {
+		t.Fatalf("LogTask() error = %v", err)
+	}
+	if err := LogTask("Clash", at(10, 0), at(11, 0), StartOptions{}); err == nil {
+		t.Error("Expected overlapping entry to be rejected")
+	}
+	if err := LogTask("Backwards", at(12, 0), at(11, 0), StartOptions{}); err == nil {
+		t.Error("Expected end before start to be rejected")
+	}
+	if err := LogTask("Adjacent", at(10, 15), at(11, 0), StartOptions{}); err != nil {
+		t.Errorf("Expected adjacent entry to be accepted, got %v", err)
+	}
+
+	data, err := LoadData()
+	if err != nil {
+		t.Fatalf("LoadData() error = %v", err)
+	}
+	if len(data.Entries) != 2 {
+		t.Errorf("Expected 2 entries, got %d", len(data.Entries))
+	}
+}
+
+func TestParseTimeExpr(t *testing.T) {
+	// Friday 16 October 2026, 14:30
+	now := time.Date(2026, 10, 16, 14, 30, 0, 0, time.Local)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"now", now},
+		{"09:05", at(10, 16, 9, 5)},
+		{"3pm", at(10, 16, 15, 0)},
+		{"2026-10-14", at(10, 14, 0, 0)},
+		{"2026-10-14 17:30", at(10, 14, 17, 30)},
+		{"2026-10-14T17:30", at(10, 14, 17, 30)},
+		{"yesterday", at(10, 15, 0, 0)},
+		{"yesterday 17:00", at(10, 15, 17, 0)},
+		{"monday 09:00", at(10, 12, 9, 0)},
+		{"friday", at(10, 16, 0, 0)},
+		{"10 min ago", at(10, 16, 14, 20)},
+		{"1h30m ago", at(10, 16, 13, 0)},
+		{"2 hours ago", at(10, 16, 12, 30)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeExpr(tt.expr, now)
+		if err != nil {
+			t.Errorf("parseTimeExpr(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimeExpr(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "soon", "25:00", "10 parsecs ago"} {
+		if _, err := parseTimeExpr(bad, now); err == nil {
+			t.Errorf("parseTimeExpr(%q) expected error", bad)
+		}
+	}
+}
+
+func TestResolveTimeArg(t *testing.T) {
+	now := time.Date(2026, 10, 16, 14, 30, 0, 0, time.Local)
+	base := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local)
+
+	got, err := resolveTimeArg("-30", base, now)
+	if err != nil || !got.Equal(base.Add(-30*time.Minute)) {
+		t.Errorf("resolveTimeArg(-30) = %v, %v; want 30 minutes earlier", got, err)
+	}
+
+	// Bare clock times stay on the entry's own day
+	got, err = resolveTimeArg("09:05", base, now)
+	if err != nil || !got.Equal(time.Date(2026, 10, 14, 9, 5, 0, 0, time.Local)) {
+		t.Errorf("resolveTimeArg(09:05) = %v, %v; want 09:05 on the entry's day", got, err)
+	}
+}
+
+func TestExportWriters(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	entries := []TimeEntry{
+		{ID: "abc123", Title: "Review, part 1", Project: "acme", StartTime: start, EndTime: &end, Notes: "line one\nline two"},
+	}
+
+	var csvOut bytes.Buffer
+	if err := writeCSV(&csvOut, entries); err != nil {
+		t.Fatalf("writeCSV() error = %v", err)
+	}
+	if !strings.Contains(csvOut.String(), `abc123,"Review, part 1",acme,,2026-10-16T09:00:00Z,2026-10-16T10:30:00Z,5400,`) {
+		t.Errorf("Unexpected CSV output:\n%s", csvOut.String())
+	}
+
+	var icsOut bytes.Buffer
+	if err := writeICS(&icsOut, entries); err != nil {
+		t.Fatalf("writeICS() error = %v", err)
+	}
+	for _, want := range []string{
+		"DTSTART:20261016T090000Z\r\n",
+		"DTEND:20261016T103000Z\r\n",
+		`SUMMARY:Review\, part 1` + "\r\n",
+		`DESCRIPTION:line one\nline two` + "\r\n",
+	} {
+		if !strings.Contains(icsOut.String(), want) {
+			t.Errorf("ICS output missing %q", want)
+		}
+	}
+
+	folded := icsFold("DESCRIPTION:" + strings.Repeat("x", 100))
+	for _, line := range strings.Split(folded, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("icsFold left a %d octet line", len(line))
+		}
+	}
+}
+
+func TestImportEntriesIdempotent(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	path := t.TempDir() + "/toggl.csv"
+	report := "User,Email,Client,Project,Task,Description,Billable,Start date,Start time,End date,End time,Duration,Tags\n" +
+		"Ann,a@x,Acme,Acme Web,,Fix login,Yes,2026-10-14,09:00:00,2026-10-14,10:30:00,01:30:00,\"backend, urgent\"\n" +
+		"Ann,a@x,Acme,Acme Web,,Review,Yes,2026-10-14,10:30:00,2026-10-14,11:00:00,00:30:00,\n" +
+		"Ann,a@x,Acme,Acme Web,,Backwards,Yes,2026-10-14,12:00:00,2026-10-14,11:30:00,00:30:00,\n"
+	if err := os.WriteFile(path, []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := captureStdout(t, func() error { return ImportEntries("toggl", path, true) })
+	if err != nil {
+		t.Fatalf("ImportEntries(dry run) error = %v", err)
+	}
+	if !strings.Contains(string(out), "1 invalid") || strings.Contains(string(out), "running") {
+		t.Errorf("Expected the backwards row reported as invalid, got %q", out)
+	}
+	data, _ := LoadData()
+	if len(data.Entries) != 0 {
+		t.Fatalf("Dry run saved %d entries", len(data.Entries))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ImportEntries("toggl", path, false); err != nil {
+			t.Fatalf("ImportEntries() error = %v", err)
+		}
+	}
+	data, _ = LoadData()
+	if len(data.Entries) != 2 {
+		t.Fatalf("Expected 2 entries after importing twice, got %d", len(data.Entries))
+	}
+	entry := data.Entries[0]
+	if entry.Title != "Fix login" || entry.Project != "Acme-Web" || !entry.HasTag("urgent") {
+		t.Errorf("Unexpected imported entry: %+v", entry)
+	}
+}
+
+func TestParseTimewarrior(t *testing.T) {
+	raw := []byte(`[{"id":1,"start":"20261013T080000Z","end":"20261013T090000Z","tags":["Planning","ops"]},
+		{"id":2,"start":"20261013T100000Z","end":"20261013T101500Z","tags":["ops"],"annotation":"Deploy"}]`)
+
+	entries, err := parseTimewarrior(raw)
+	if err != nil {
+		t.Fatalf("parseTimewarrior() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Title != "Planning" || !entries[0].HasTag("ops") || entries[0].Duration() != time.Hour {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Title != "Deploy" || !entries[1].HasTag("ops") {
+		t.Errorf("Unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestPeriodRange(t *testing.T) {
+	// Wednesday 14 October 2026
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, time.Local)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2026, month, d, 0, 0, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		period, value string
+		weekStart     time.Weekday
+		start, end    time.Time
+	}{
+		{"today", "", time.Monday, day(10, 14), day(10, 15)},
+		{"yesterday", "", time.Monday, day(10, 13), day(10, 14)},
+		{"week", "", time.Monday, day(10, 12), day(10, 19)},
+		{"week", "", time.Sunday, day(10, 11), day(10, 18)},
+		{"week", "2026-W41", time.Monday, day(10, 5), day(10, 12)},
+		{"week", "2026-W41", time.Sunday, day(10, 4), day(10, 11)},
+		{"last-week", "", time.Monday, day(10, 5), day(10, 12)},
+		{"month", "", time.Monday, day(10, 1), day(11, 1)},
+		{"month", "2026-09", time.Monday, day(9, 1), day(10, 1)},
+		{"last-month", "", time.Monday, day(9, 1), day(10, 1)},
+		{"year", "", time.Monday, day(1, 1), time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		start, end, _, err := periodRange(tt.period, tt.value, now, tt.weekStart)
+		if err != nil {
+			t.Errorf("periodRange(%q, %q) error = %v", tt.period, tt.value, err)
+			continue
+		}
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("periodRange(%q, %q, %v) = %v - %v, want %v - %v",
+				tt.period, tt.value, tt.weekStart, start, end, tt.start, tt.end)
+		}
+	}
+
+	if _, _, _, err := periodRange("week", "2026-W54", now, time.Monday); err == nil {
+		t.Error("Expected invalid ISO week to be rejected")
+	}
+}
+
+func TestSplitByDay(t *testing.T) {
+	start := time.Date(2026, 10, 15, 22, 0, 0, 0, time.Local)
+	end := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+	entry := TimeEntry{ID: "1", Title: "Late night", StartTime: start, EndTime: &end}
+
+	spans := splitByDay(&entry, time.Time{}, time.Time{})
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 day spans, got %d", len(spans))
+	}
+	if spans[0].Duration != 2*time.Hour || spans[1].Duration != 2*time.Hour {
+		t.Errorf("Expected 2h on each day, got %v and %v", spans[0].Duration, spans[1].Duration)
+	}
+	if !spans[1].Day.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Expected second span on 16 Oct, got %v", spans[1].Day)
+	}
+
+	// Clipping to a window that starts at 01:00 on the second day
+	windowStart := time.Date(2026, 10, 16, 1, 0, 0, 0, time.Local)
+	if d := entry.DurationWithin(windowStart, time.Time{}); d != time.Hour {
+		t.Errorf("DurationWithin() = %v, want 1h", d)
+	}
+	spans = splitByDay(&entry, windowStart, time.Time{})
+	if len(spans) != 1 || spans[0].Duration != time.Hour {
+		t.Errorf("Expected a single 1h span after clipping, got %v", spans)
+	}
+
+	// Clipping to a window that ends mid-day: the spans add up to the
+	// clipped duration rather than running on to midnight
+	dayStart := time.Date(2026, 10, 15, 0, 0, 0, 0, time.Local)
+	windowEnd := time.Date(2026, 10, 16, 1, 0, 0, 0, time.Local)
+	spans = splitByDay(&entry, dayStart, windowEnd)
+	var total time.Duration
+	for _, span := range spans {
+		total += span.Duration
+	}
+	if want := entry.DurationWithin(dayStart, windowEnd); len(spans) != 2 || total != want || spans[1].Duration != time.Hour {
+		t.Errorf("Expected spans adding up to %v with 1h on 16 Oct, got %v", want, spans)
+	}
+
+	if d := entry.DurationWithin(end, end.Add(time.Hour)); d != 0 {
+		t.Errorf("DurationWithin() outside the entry = %v, want 0", d)
+	}
+}
+
+func TestSortAndTopSummaryRows(t *testing.T) {
+	base := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	rows := []summaryRow{
+		{Key: "beta", Duration: time.Hour, First: base.Add(2 * time.Hour), Last: base.Add(2 * time.Hour)},
+		{Key: "alpha", Duration: time.Hour, First: base, Last: base.Add(5 * time.Hour)},
+		{Key: "gamma", Duration: 3 * time.Hour, First: base.Add(time.Hour), Last: base.Add(time.Hour)},
+	}
+
+	keys := func(rows []summaryRow) string {
+		var k []string
+		for _, row := range rows {
+			k = append(k, row.Key)
+		}
+		return strings.Join(k, ",")
+	}
+
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"duration", "gamma,alpha,beta"}, // equal durations fall back to the key
+		{"title", "alpha,beta,gamma"},
+		{"first", "alpha,gamma,beta"},
+		{"last", "alpha,beta,gamma"},
+	}
+	for _, tt := range tests {
+		if err := sortSummaryRows(rows, tt.sort); err != nil {
+			t.Fatalf("sortSummaryRows(%q) error = %v", tt.sort, err)
+		}
+		if got := keys(rows); got != tt.want {
+			t.Errorf("sortSummaryRows(%q) = %s, want %s", tt.sort, got, tt.want)
+		}
+	}
+
+	top := topSummaryRows(rows, 1)
+	if len(top) != 2 || top[1].Duration != 4*time.Hour {
+		t.Errorf("topSummaryRows(1) = %v, want the first row plus 4h of others", top)
+	}
+	if len(rows) != 3 {
+		t.Errorf("topSummaryRows modified its input")
+	}
+
+	if got := formatReportDuration(90*time.Minute, true); got != "1.50h" {
+		t.Errorf("formatReportDuration(decimal) = %q, want 1.50h", got)
+	}
+}
+
+func TestTopSummaryRowsMergesDays(t *testing.T) {
+	rows := []summaryRow{
+		{Key: "a", Duration: 3 * time.Hour, Days: map[string]time.Duration{"2026-10-12": 3 * time.Hour}},
+		{Key: "b", Duration: 2 * time.Hour, Days: map[string]time.Duration{"2026-10-12": time.Hour, "2026-10-13": time.Hour}},
+		{Key: "c", Duration: time.Hour, Days: map[string]time.Duration{"2026-10-13": time.Hour}},
+	}
+
+	top := topSummaryRows(rows, 1)
+	other := top[len(top)-1]
+	if other.Days["2026-10-12"] != time.Hour || other.Days["2026-10-13"] != 2*time.Hour {
+		t.Errorf("Expected other row to merge daily durations, got %v", other.Days)
+	}
+}
+
+func TestRoundingAndRates(t *testing.T) {
+	tests := []struct {
+		mode string
+		in   time.Duration
+		want time.Duration
+	}{
+		{"", 67 * time.Minute, 75 * time.Minute},
+		{"up", 60 * time.Minute, 60 * time.Minute},
+		{"down", 67 * time.Minute, 60 * time.Minute},
+		{"nearest", 67 * time.Minute, 60 * time.Minute},
+		{"nearest", 68 * time.Minute, 75 * time.Minute},
+	}
+	for _, tt := range tests {
+		r := RoundingConfig{Minutes: 15, Mode: tt.mode}
+		if got := r.Round(tt.in); got != tt.want {
+			t.Errorf("Round(%v, %q) = %v, want %v", tt.in, tt.mode, got, tt.want)
+		}
+	}
+
+	config := &Config{Rates: RateConfig{
+		Default:  100,
+		Projects: map[string]float64{"acme": 120},
+		Tags:     map[string]float64{"urgent": 150},
+	}}
+	if rate := config.RateFor(&TimeEntry{Project: "acme", Tags: []string{"urgent"}}); rate != 150 {
+		t.Errorf("Expected tag rate to win, got %v", rate)
+	}
+	if rate := config.RateFor(&TimeEntry{Project: "acme"}); rate != 120 {
+		t.Errorf("Expected project rate, got %v", rate)
+	}
+	if rate := config.RateFor(&TimeEntry{Project: "other"}); rate != 100 {
+		t.Errorf("Expected default rate, got %v", rate)
+	}
+}
+
+func TestBuildInvoice(t *testing.T) {
+	day := time.Date(2026, 9, 3, 9, 0, 0, 0, time.Local)
+	span := func(offset, length time.Duration) (time.Time, *time.Time) {
+		end := day.Add(offset + length)
+		return day.Add(offset), &end
+	}
+	no := false
+
+	data := &TimeData{}
+	for _, e := range []struct {
+		title, project string
+		offset, length time.Duration
+		billable       *bool
+	}{
+		{"Fix login", "acme", 0, 67 * time.Minute, nil},
+		{"Fix login", "acme", 2 * time.Hour, 20 * time.Minute, nil},
+		{"Lunch", "acme", 3 * time.Hour, time.Hour, &no},
+		{"Other client", "globex", 5 * time.Hour, time.Hour, nil},
+	} {
+		start, end := span(e.offset, e.length)
+		data.Entries = append(data.Entries, TimeEntry{Title: e.title, Project: e.project, StartTime: start, EndTime: end, Billable: e.billable})
+	}
+
+	config := &Config{Rates: RateConfig{Default: 100}, Rounding: RoundingConfig{Minutes: 15}}
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local)
+	invoice := BuildInvoice(data, config, "acme", from, from.AddDate(0, 1, 0), "September 2026")
+
+	if len(invoice.Sections) != 1 || len(invoice.Sections[0].Items) != 1 {
+		t.Fatalf("Expected one section with one line item, got %+v", invoice.Sections)
+	}
+	item := invoice.Sections[0].Items[0]
+	if item.Entries != 2 || item.Hours != 1.75 || item.Amount != 175 {
+		t.Errorf("Expected 2 entries rounded to 1.75h for 175.00, got %+v", item)
+	}
+	if invoice.Total != 175 {
+		t.Errorf("Expected total 175, got %v", invoice.Total)
+	}
+}
+
+func TestDurationExcludesBreaks(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	breakStart := start.Add(3 * time.Hour)
+	breakEnd := breakStart.Add(45 * time.Minute)
+
+	entry := TimeEntry{
+		ID:        "1",
+		Title:     "Long task",
+		StartTime: start,
+		Breaks:    []Break{{Start: breakStart, End: &breakEnd}},
+	}
+	entry.Stop(start.Add(8 * time.Hour))
+
+	if d := entry.Duration(); d != 8*time.Hour-45*time.Minute {
+		t.Errorf("Duration() = %v, want 7h15m", d)
+	}
+	if d := entry.BreakDuration(); d != 45*time.Minute {
+		t.Errorf("BreakDuration() = %v, want 45m", d)
+	}
+	// Window ending half way through the break
+	if d := entry.DurationWithin(start, breakStart.Add(20*time.Minute)); d != 3*time.Hour {
+		t.Errorf("DurationWithin() = %v, want 3h", d)
+	}
+
+	// Stopping while paused closes the open break
+	paused := TimeEntry{ID: "2", Title: "Paused", StartTime: start, Breaks: []Break{{Start: breakStart}}}
+	if !paused.IsPaused() {
+		t.Fatal("Expected entry with an open break to be paused")
+	}
+	paused.Stop(breakStart.Add(time.Hour))
+	if paused.IsPaused() || paused.Breaks[0].End == nil || !paused.Breaks[0].End.Equal(breakStart.Add(time.Hour)) {
+		t.Errorf("Expected Stop to close the open break, got %+v", paused.Breaks)
+	}
+	if d := paused.Duration(); d != 3*time.Hour {
+		t.Errorf("Duration() after stopping while paused = %v, want 3h", d)
+	}
+}
+
+func TestMatchTitles(t *testing.T) {
+	now := time.Now()
+	data := &TimeData{
+		Entries: []TimeEntry{
+			{ID: "1", Title: "Fix login bug", StartTime: now.Add(-3 * time.Hour)},
+			{ID: "2", Title: "Write docs", StartTime: now.Add(-2 * time.Hour)},
+			{ID: "3", Title: "Fix logout", StartTime: now.Add(-1 * time.Hour)},
+		},
+	}
+
+	if got := strings.Join(matchTitles(data, "fix"), ","); got != "Fix logout,Fix login bug" {
+		t.Errorf("matchTitles(fix) = %s, want most recent prefix match first", got)
+	}
+	if got := matchTitles(data, "wrte docs"); len(got) != 1 || got[0] != "Write docs" {
+		t.Errorf("matchTitles(wrte docs) = %v, want a typo-tolerant match", got)
+	}
+	if got := matchTitles(data, "deploy"); len(got) != 0 {
+		t.Errorf("matchTitles(deploy) = %v, want no matches", got)
+	}
+
+	if title, ok := canonicalTitle(data, "fix  LOGIN bug"); !ok || title != "Fix login bug" {
+		t.Errorf("canonicalTitle() = %q, %v; want the existing title", title, ok)
+	}
+
+	// Subsequence matching works on runes, not bytes
+	for _, tt := range []struct{ query, title string }{{"rééqp", "Réunion équipe"}, {"会準", "会議の準備"}} {
+		if _, ok := fuzzyScore(tt.query, tt.title); !ok {
+			t.Errorf("fuzzyScore(%q, %q) did not match", tt.query, tt.title)
+		}
+	}
+}
+
+func TestContinueTask(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	if err := StartTask("Fix login +backend @acme", StartOptions{NonBillable: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := StopTask(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ContinueTask(""); err != nil {
+		t.Fatalf("ContinueTask() error = %v", err)
+	}
+
+	data, _ := LoadData()
+	running := findRunningTask(data)
+	if running == nil {
+		t.Fatal("Expected a running task after continue")
+	}
+	if running.Title != "Fix login" || running.Project != "acme" || !running.HasTag("backend") || running.IsBillable() {
+		t.Errorf("Continued entry did not copy metadata: %+v", running)
+	}
+}
+
+func TestStopExpiredTimebox(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	if err := StartTask("Write tests", StartOptions{At: time.Now().Add(-40 * time.Minute), For: 25 * time.Minute, Tags: []string{pomodoroTag}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stopExpiredTimebox(); err != nil {
+		t.Fatalf("stopExpiredTimebox() error = %v", err)
+	}
+
+	data, _ := LoadData()
+	entry := data.Entries[0]
+	if entry.IsRunning() {
+		t.Fatal("Expected expired timebox to be stopped")
+	}
+	if !entry.EndTime.Equal(*entry.PlannedEnd) {
+		t.Errorf("Expected entry to stop at its planned end %v, got %v", entry.PlannedEnd, entry.EndTime)
+	}
+	if !isCompletedPomodoro(&entry) {
+		t.Error("Expected entry to count as a completed pomodoro")
+	}
+}
+
+func TestAutoStopCapFor(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	late := time.Date(2026, 10, 16, 20, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		config AutoStopConfig
+		start  time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{"no rules", AutoStopConfig{}, start, time.Time{}, false},
+		{"max running", AutoStopConfig{MaxRunning: "10h"}, start, start.Add(10 * time.Hour), true},
+		{"end of day", AutoStopConfig{EndOfDay: "18:00"}, start, time.Date(2026, 10, 16, 18, 0, 0, 0, time.Local), true},
+		{"earliest wins", AutoStopConfig{MaxRunning: "4h", EndOfDay: "18:00"}, start, start.Add(4 * time.Hour), true},
+		{"started after end of day", AutoStopConfig{EndOfDay: "18:00"}, late, time.Date(2026, 10, 17, 18, 0, 0, 0, time.Local), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := tt.config.CapFor(&TimeEntry{StartTime: tt.start})
+			if err != nil {
+				t.Fatalf("CapFor() error = %v", err)
+			}
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("CapFor() = %v, %t, want %v, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if _, _, err := (AutoStopConfig{MaxRunning: "forever"}).CapFor(&TimeEntry{StartTime: start}); err == nil {
+		t.Error("Expected error for invalid max_running")
+	}
+}
+
+func TestCapForgottenTimer(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	os.MkdirAll(configDir+"/timetrack", 0755)
+	if err := os.WriteFile(configDir+"/timetrack/config.json", []byte(`{"auto_stop": {"max_running": "8h"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-30 * time.Hour)
+	if err := StartTask("Forgotten", StartOptions{At: start}); err != nil {
+		t.Fatal(err)
+	}
+	if err := capForgottenTimer(true); err != nil {
+		t.Fatalf("capForgottenTimer() error = %v", err)
+	}
+
+	data, _ := LoadData()
+	entry := data.Entries[0]
+	if entry.IsRunning() {
+		t.Fatal("Expected forgotten timer to be stopped")
+	}
+	if want := start.Add(8 * time.Hour); !entry.EndTime.Equal(want) {
+		t.Errorf("Expected entry to stop at the cap %v, got %v", want, entry.EndTime)
+	}
+	if !entry.AutoStopped {
+		t.Error("Expected entry to be flagged as auto-stopped")
+	}
+
+	if err := EditTask(entry.ID, EditOptions{End: "-60"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = LoadData()
+	if data.Entries[0].AutoStopped {
+		t.Error("Expected editing to clear the auto-stopped flag")
+	}
+}
+
+func TestSaveDataKeepsBackups(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	for i := 0; i < maxBackups+3; i++ {
+		if err := LogTask(fmt.Sprintf("Task %d", i), time.Now().Add(-time.Duration(i+1)*time.Hour), time.Now().Add(-time.Duration(i+1)*time.Hour+30*time.Minute), StartOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := listBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != maxBackups {
+		t.Errorf("Expected %d backups, got %d", maxBackups, len(backups))
+	}
+
+	path, _ := getDataFilePath()
+	os.WriteFile(path, []byte(`{"entries": [`), 0644)
+	if _, err := LoadData(); err == nil {
+		t.Fatal("Expected error loading a truncated data file")
+	}
+
+	if err := RestoreBackup("0"); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	data, err := LoadData()
+	if err != nil {
+		t.Fatalf("LoadData() after restore error = %v", err)
+	}
+	if len(data.Entries) != maxBackups+2 {
+		t.Errorf("Expected the newest backup with %d entries, got %d", maxBackups+2, len(data.Entries))
+	}
+}
+
+func TestRestoreBackupIsJournaled(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+	defer func() { journalOp = "save" }()
+
+	now := time.Now().Truncate(time.Minute)
+	if err := LogTask("Work", now.Add(-2*time.Hour), now.Add(-time.Hour), StartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	journalOp = "delete"
+	if err := DeleteTask("0"); err != nil {
+		t.Fatal(err)
+	}
+
+	journalOp = "restore-backup"
+	if err := RestoreBackup("0"); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	records, err := readJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := records[len(records)-1]; last.Op != "restore-backup" || len(last.Changes) != 1 || last.Changes[0].After == nil {
+		t.Errorf("Expected the restore to be journaled as adding Work, got %+v", last)
+	}
+
+	// Undoing the restore, then the delete, brings back each state in turn
+	for _, want := range []int{0, 1} {
+		if _, err := captureStdout(t, Undo); err != nil {
+			t.Fatalf("Undo() error = %v", err)
+		}
+		data, err := LoadData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Entries) != want {
+			t.Errorf("Expected %d entries after undo, got %d", want, len(data.Entries))
+		}
+	}
+}
+
+func TestMigrateToSQLite(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	now := time.Now()
+	day := startOfDay(now)
+	for i, title := range []string{"Old", "Yesterday", "Today"} {
+		start := day.AddDate(0, 0, []int{-30, -1, 0}[i])
+		if err := LogTask(title, start, start.Add(time.Minute), StartOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jsonPath, _ := getDataFilePath()
+	if err := MigrateStore("sqlite"); err != nil {
+		t.Fatalf("MigrateStore() error = %v", err)
+	}
+	if _, err := os.Stat(jsonPath); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be retired after migrating", jsonPath)
+	}
+	os.Setenv("TIMETRACK_FILE", strings.TrimSuffix(jsonPath, ".json")+".db")
+
+	data, err := LoadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Entries) != 3 {
+		t.Fatalf("Expected 3 migrated entries, got %d", len(data.Entries))
+	}
+
+	recent, err := LoadDataBetween(day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent.Entries) != 2 {
+		t.Errorf("Expected 2 entries in range, got %d", len(recent.Entries))
+	}
+
+	if err := DeleteTask(data.Entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := NoteTask("0", "after migration"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = LoadData()
+	if len(data.Entries) != 2 || data.Entries[1].Notes != "after migration" {
+		t.Errorf("Expected delete and note to be saved, got %+v", data.Entries)
+	}
+
+	if err := MigrateStore("json"); err != nil {
+		t.Fatalf("MigrateStore() back to json error = %v", err)
+	}
+	os.Setenv("TIMETRACK_FILE", jsonPath)
+	data, _ = LoadData()
+	if len(data.Entries) != 2 {
+		t.Errorf("Expected 2 entries after migrating back, got %d", len(data.Entries))
+	}
+}
+
+func TestGetDataFilePath(t *testing.T) {
+	home := t.TempDir()
+	dataHome := filepath.Join(home, "data")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	os.MkdirAll(filepath.Join(dataHome, "timetrack", "profiles"), 0755)
+	os.WriteFile(filepath.Join(dataHome, "timetrack", "profiles", "sql.db"), nil, 0644)
+
+	tests := []struct {
+		name       string
+		file       string
+		profile    string
+		envFile    string
+		envProfile string
+		legacy     bool
+		want       string
+		wantErr    bool
+	}{
+		{name: "xdg default", want: filepath.Join(dataHome, "timetrack", "timetrack.json")},
+		{name: "legacy file kept", legacy: true, want: filepath.Join(home, ".timetrack.json")},
+		{name: "env file", envFile: "/tmp/tt.json", want: "/tmp/tt.json"},
+		{name: "flag file beats env", file: "/tmp/flag.json", envFile: "/tmp/tt.json", want: "/tmp/flag.json"},
+		{name: "env profile", envProfile: "client-a", want: filepath.Join(dataHome, "timetrack", "profiles", "client-a.json")},
+		{name: "flag profile beats env file", profile: "client-b", envFile: "/tmp/tt.json", want: filepath.Join(dataHome, "timetrack", "profiles", "client-b.json")},
+		{name: "migrated profile", profile: "sql", want: filepath.Join(dataHome, "timetrack", "profiles", "sql.db")},
+		{name: "invalid profile", profile: "../other", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataFile, dataProfile = tt.file, tt.profile
+			defer func() { dataFile, dataProfile = "", "" }()
+			t.Setenv("TIMETRACK_FILE", tt.envFile)
+			t.Setenv("TIMETRACK_PROFILE", tt.envProfile)
+			legacy := filepath.Join(home, ".timetrack.json")
+			if tt.legacy {
+				os.WriteFile(legacy, nil, 0644)
+				defer os.Remove(legacy)
+			}
+
+			got, err := getDataFilePath()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getDataFilePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getDataFilePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+	defer applyConfig(&Config{})
+
+	tests := []struct {
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"list.limit", "25", false},
+		{"rates.projects.acme", "95.5", false},
+		{"currency", "100", false},
+		{"display.clock", "12h", false},
+		{"hooks.on_stop", "echo done > /tmp/log", false},
+		{"list.limit", "many", true},
+		{"display.clock", "13h", true},
+		{"week_start", "someday", true},
+		{"no.such.key", "1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			err := ConfigSet(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ConfigSet(%q, %q) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+			}
+		})
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.List.Limit == nil || *config.List.Limit != 25 {
+		t.Errorf("Expected list.limit 25, got %v", config.List.Limit)
+	}
+	if config.Rates.Projects["acme"] != 95.5 || config.Currency != "100" {
+		t.Errorf("Expected rate 95.5 and currency \"100\", got %v and %q", config.Rates.Projects["acme"], config.Currency)
+	}
+	if config.Hooks.OnStop != "echo done > /tmp/log" {
+		t.Errorf("Expected hook to round-trip, got %q", config.Hooks.OnStop)
+	}
+
+	if err := applyConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 16, 15, 4, 0, 0, time.Local)
+	if got := formatDateTime(at); got != "2026-10-16 3:04pm" {
+		t.Errorf("formatDateTime() with 12h clock = %q", got)
+	}
+
+	if err := ConfigUnset("list.limit"); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = LoadConfig()
+	if config.List.Limit != nil {
+		t.Errorf("Expected list.limit to be unset, got %d", *config.List.Limit)
+	}
+	if err := ConfigUnset("list.limit"); err == nil {
+		t.Error("Expected error unsetting a key that is not set")
+	}
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func() error) ([]byte, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout, textOut = w, w
+	fnErr := fn()
+	os.Stdout, textOut = orig, orig
+	w.Close()
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	return buf.Bytes(), fnErr
+}
+
+func TestJSONOutput(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	jsonOutput = true
+	defer func() { jsonOutput = false }()
+
+	now := time.Now().Truncate(time.Minute)
+	if _, err := captureStdout(t, func() error {
+		return LogTask("Review @acme", now.Add(-2*time.Hour), now.Add(-time.Hour), StartOptions{})
+	}); err != nil {
+		t.Fatalf("LogTask() error = %v", err)
+	}
+	if _, err := captureStdout(t, func() error { return StartTask("Write tests +go", StartOptions{}) }); err != nil {
+		t.Fatalf("StartTask() error = %v", err)
+	}
+
+	out, err := captureStdout(t, func() error { return ListTasks(0, EntryFilter{}) })
+	if err != nil {
+		t.Fatalf("ListTasks() error = %v", err)
+	}
+	var list struct {
+		Entries []struct {
+			Index   *int     `json:"index"`
+			Title   string   `json:"title"`
+			Project string   `json:"project"`
+			Tags    []string `json:"tags"`
+			Running bool     `json:"running"`
+		} `json:"entries"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		t.Fatalf("list output is not JSON: %v\n%s", err, out)
+	}
+	if list.Total != 2 || len(list.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", list)
+	}
+	first := list.Entries[0]
+	if first.Index == nil || *first.Index != 0 || first.Title != "Write tests" || !first.Running || len(first.Tags) != 1 {
+		t.Errorf("Unexpected newest entry %+v", first)
+	}
+	if list.Entries[1].Project != "acme" {
+		t.Errorf("Expected project acme, got %q", list.Entries[1].Project)
+	}
+
+	out, err = captureStdout(t, Status)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	var status struct {
+		Running *struct {
+			Title string `json:"title"`
+		} `json:"running"`
+		TodaySeconds int64 `json:"today_seconds"`
+	}
+	if err := json.Unmarshal(out, &status); err != nil {
+		t.Fatalf("status output is not JSON: %v\n%s", err, out)
+	}
+	if status.Running == nil || status.Running.Title != "Write tests" {
+		t.Errorf("Expected the running task in status, got %s", out)
+	}
+
+	out, err = captureStdout(t, func() error { return Summary(SummaryOptions{Period: "today", GroupBy: "project"}) })
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	var summary summaryJSON
+	if err := json.Unmarshal(out, &summary); err != nil {
+		t.Fatalf("summary output is not JSON: %v\n%s", err, out)
+	}
+	if summary.Entries != 2 || len(summary.Groups) != 2 || summary.TotalSeconds < 3600 {
+		t.Errorf("Unexpected summary %s", out)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	data := &TimeData{Entries: []TimeEntry{{ID: "abc123", Title: "Task"}}}
+	_, noEntryErr := resolveEntry(data, "zzz")
+	_, missingErr := os.ReadFile(filepath.Join(t.TempDir(), "missing"))
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"coded", withCode(codeConflict, fmt.Errorf("overlap")), codeConflict},
+		{"wrapped coded", fmt.Errorf("failed: %w", withCode(codeNotFound, fmt.Errorf("gone"))), codeNotFound},
+		{"path error", missingErr, codeStorage},
+		{"plain", fmt.Errorf("boom"), codeError},
+		{"no entry", noEntryErr, codeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(tt.err); got != tt.want {
+				t.Errorf("errorCode(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputTemplates(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+	defer func() { outputTemplate = nil }()
+
+	now := time.Now().Truncate(time.Minute)
+	captureStdout(t, func() error {
+		return LogTask("Review @acme", now.Add(-2*time.Hour), now.Add(-time.Hour), StartOptions{})
+	})
+	captureStdout(t, func() error {
+		return LogTask("Write tests +go", now.Add(-time.Hour), now.Add(-30*time.Minute), StartOptions{})
+	})
+
+	dir, err := getConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(dir, 0755)
+	standup := "{{range .Groups}}- {{.Key}} {{.Duration}}\n{{end}}Total {{.Total}}"
+	if err := os.WriteFile(filepath.Join(dir, "summary.standup.tmpl"), []byte(standup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Reachable as "summary.../../x.tmpl" if names were joined unchecked
+	if err := os.WriteFile(filepath.Join(dir, "x.tmpl"), []byte("escaped"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		format  string
+		run     func() error
+		want    string
+		wantErr bool
+	}{
+		{"list", "list", "{{.Index}} {{.Title}} {{.Duration}} {{hours .Duration}}",
+			func() error { return ListTasks(0, EntryFilter{}) }, "0 Write tests 30m 0s 0.50\n1 Review 1h 0m 1.00\n", false},
+		{"status idle", "status", "{{if .Running}}{{.Title}}{{else}}idle{{end}}", Status, "idle\n", false},
+		{"view", "view", "{{.Project}}/{{join .Tags \",\"}}",
+			func() error { return ViewTask("1") }, "acme/\n", false},
+		{"named summary", "summary", "standup",
+			func() error { return Summary(SummaryOptions{From: now.Add(-3 * time.Hour), To: now.Add(time.Minute)}) }, "- Review 1h 0m\n- Write tests 30m 0s\nTotal 1h 30m\n", false},
+		{"missing template", "summary", "nope", nil, "", true},
+		{"path in name", "summary", "../../x", nil, "", true},
+		{"bad field", "list", "{{.Nope}}", func() error { return ListTasks(0, EntryFilter{}) }, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := outputFormat{tt.command, true}.Set(tt.format)
+			if err == nil && tt.run != nil {
+				var out []byte
+				out, err = captureStdout(t, tt.run)
+				if err == nil && string(out) != tt.want {
+					t.Errorf("Output = %q, want %q", out, tt.want)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShortStatus(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	durations := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "0m"},
+		{7*time.Minute + 59*time.Second, "7m"},
+		{72 * time.Minute, "1h12m"},
+		{10*time.Hour + 5*time.Minute, "10h05m"},
+	}
+	for _, tt := range durations {
+		if got := formatShortDuration(tt.d); got != tt.want {
+			t.Errorf("formatShortDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+
+	out, err := captureStdout(t, ShortStatus)
+	if err != nil || len(out) != 0 {
+		t.Fatalf("ShortStatus() when idle = %q, %v; want no output", out, err)
+	}
+
+	captureStdout(t, func() error {
+		return StartTask("Fix login", StartOptions{At: time.Now().Add(-72 * time.Minute)})
+	})
+	statePath := getStatePath(os.Getenv("TIMETRACK_FILE"))
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("Expected a state file after start: %v", err)
+	}
+	out, _ = captureStdout(t, ShortStatus)
+	if string(out) != "⏱ Fix login 1h12m\n" {
+		t.Errorf("ShortStatus() = %q", out)
+	}
+
+	// A data file changed behind the state file's back wins over the cache
+	data, _ := LoadData()
+	data.Entries[0].Title = "Renamed"
+	raw, _ := json.Marshal(data)
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(os.Getenv("TIMETRACK_FILE"), raw, 0644)
+	os.Chtimes(os.Getenv("TIMETRACK_FILE"), later, later)
+	delete(openStores, os.Getenv("TIMETRACK_FILE"))
+	out, _ = captureStdout(t, ShortStatus)
+	if !strings.HasPrefix(string(out), "⏱ Renamed ") {
+		t.Errorf("ShortStatus() after an outside edit = %q", out)
+	}
+}
+
+func TestWatchLines(t *testing.T) {
+	origTarget := dailyTarget
+	defer func() { dailyTarget = origTarget }()
+
+	start := time.Now().Add(-(time.Hour + 2*time.Minute + 3*time.Second))
+	running := &TimeEntry{ID: "abc123", Title: "Fix login", StartTime: start}
+
+	tests := []struct {
+		name    string
+		running *TimeEntry
+		target  time.Duration
+		want    []string
+	}{
+		{"idle", nil, 0, []string{"No task is currently running", "", "Today:   2:00:00"}},
+		{"running with target", running, 8 * time.Hour, []string{
+			"Running: Fix login [abc123]",
+			"Elapsed: 1:02:03 since " + formatClock(start),
+			"Today:   2:00:00 of 8h 0m target (25%)",
+			"         [" + strings.Repeat("█", 7) + strings.Repeat("░", 23) + "]",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dailyTarget = tt.target
+			got := watchLines(tt.running, 2*time.Hour)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("watchLines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	if got := progressBar(150, 4); got != "[████]" {
+		t.Errorf("progressBar(150, 4) = %q", got)
+	}
+}
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"\x1b[A\x1b[B", []string{"up", "down"}},
+		{"\x1bOA", []string{"up"}},
+		{"\x1b[5~\x1b[6~", []string{"pgup", "pgdn"}},
+		{"\x1b", []string{"esc"}},
+		{"ab\r", []string{"a", "b", "enter"}},
+		{"é\x7f\x15\x03", []string{"é", "backspace", "ctrl-u", "ctrl-c"}},
+	}
+
+	for _, tt := range tests {
+		if got := decodeKeys([]byte(tt.in)); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("decodeKeys(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTUIHandle(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	now := time.Now().Truncate(time.Minute)
+	captureStdout(t, func() error {
+		return LogTask("Review", now.Add(-2*time.Hour), now.Add(-time.Hour), StartOptions{})
+	})
+	captureStdout(t, func() error {
+		return LogTask("Fix login", now.Add(-time.Hour), now.Add(-30*time.Minute), StartOptions{})
+	})
+
+	ui := &tui{path: os.Getenv("TIMETRACK_FILE")}
+	if err := ui.reload(); err != nil {
+		t.Fatal(err)
+	}
+	textOut = &ui.output
+	defer func() { textOut = os.Stdout }()
+
+	press := func(keys ...string) {
+		for _, key := range keys {
+			ui.handle(key, 24)
+		}
+	}
+
+	press("/", "r", "e", "v", "enter")
+	if len(ui.rows) != 1 || ui.selected().Title != "Review" {
+		t.Fatalf("Expected the filter to leave Review, got %d rows", len(ui.rows))
+	}
+
+	press("e", "ctrl-u", "C", "o", "d", "e", "enter")
+	if ui.message != "Updated title: 'Review' -> 'Code'" {
+		t.Errorf("Unexpected message %q", ui.message)
+	}
+
+	press("/", "esc", "d", "n")
+	if len(ui.data.Entries) != 2 || ui.message != "Cancelled" {
+		t.Errorf("Expected delete to be cancelled, got %d entries, message %q", len(ui.data.Entries), ui.message)
+	}
+	press("G", "d", "y")
+	if len(ui.data.Entries) != 1 || ui.data.Entries[0].Title != "Fix login" {
+		t.Errorf("Expected Code to be deleted, got %+v", ui.data.Entries)
+	}
+
+	press("s", "W", "r", "i", "t", "e", "enter")
+	if running := findRunningTask(ui.data); running == nil || running.Title != "Write" {
+		t.Errorf("Expected Write to be running, got %v", running)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	cleanup := setupTestStorage(t)
+	defer cleanup()
+	defer func() { journalOp = "save" }()
+
+	now := time.Now().Truncate(time.Minute)
+	journalOp = "log"
+	for i, title := range []string{"Review", "Fix login"} {
+		start := now.Add(-time.Duration(4-2*i) * time.Hour)
+		if _, err := captureStdout(t, func() error {
+			return LogTask(title, start, start.Add(time.Hour), StartOptions{})
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	titles := func() []string {
+		data, err := LoadData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, e := range data.Entries {
+			titles = append(titles, e.Title)
+		}
+		slices.Sort(titles)
+		return titles
+	}
+
+	journalOp = "delete"
+	if _, err := captureStdout(t, func() error { return DeleteTask("0") }); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(); len(got) != 1 {
+		t.Fatalf("Expected one entry after delete, got %v", got)
+	}
+
+	out, err := captureStdout(t, Undo)
+	if err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if !strings.Contains(string(out), "Undid #3 delete") {
+		t.Errorf("Unexpected undo output %q", out)
+	}
+	if journalOp != "delete" {
+		t.Errorf("Expected undo to leave journalOp as it was (e.g. tui), got %q", journalOp)
+	}
+	if got := titles(); !slices.Equal(got, []string{"Fix login", "Review"}) {
+		t.Errorf("Expected the deleted entry back, got %v", got)
+	}
+
+	if _, err := captureStdout(t, Redo); err != nil {
+		t.Fatalf("Redo() error = %v", err)
+	}
+	if got := titles(); len(got) != 1 {
+		t.Errorf("Expected redo to delete again, got %v", got)
+	}
+	if _, err := captureStdout(t, Redo); err == nil {
+		t.Error("Expected nothing to redo")
+	}
+
+	records, err := readJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, undone := undoStacks(records)
+	if len(done) != 3 || len(undone) != 0 {
+		t.Errorf("Expected 3 undoable and 0 redoable operations, got %d and %d", len(done), len(undone))
+	}
+
+	// A change the journal did not see makes the undo refuse
+	journalOp = "edit"
+	if _, err := captureStdout(t, func() error { return EditTask("0", EditOptions{Title: "Triage"}) }); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("TIMETRACK_FILE")
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, bytes.Replace(raw, []byte("Triage"), []byte("Hand edit"), 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := captureStdout(t, Undo); errorCode(err) != codeConflict {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+
+	out, err = captureStdout(t, func() error { return ShowHistory(0) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"edited Triage", "delete         deleted", "redo #3"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("History missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MigrateStore moves all entries from the active store to a store of the
+// given kind (sqlite or json) next to it. The old file is kept with a
+// .migrated suffix, which also makes the new store the active one.
+func MigrateStore(to string) error {
+	var ext string
+	switch to {
+	case "sqlite":
+		ext = ".db"
+	case "json":
+		ext = ".json"
+	default:
+		return fmt.Errorf("invalid store %q (expected sqlite or json)", to)
+	}
+
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path, err := getDataFilePath()
+	if err != nil {
+		return err
+	}
+	if isSQLitePath(path) == (to == "sqlite") {
+		return fmt.Errorf("already using the %s store (%s)", to, path)
+	}
+
+	target := strings.TrimSuffix(path, filepath.Ext(path)) + ext
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("%s already exists; move it aside first", target)
+	}
+
+	src, err := openStore()
+	if err != nil {
+		return err
+	}
+	data, err := src.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	dst := newStore(target)
+	if err := dst.Save(data); err != nil {
+		os.Remove(target)
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	check, err := dst.Load()
+	if err == nil && len(check.Entries) != len(data.Entries) {
+		err = fmt.Errorf("wrote %d of %d entries", len(check.Entries), len(data.Entries))
+	}
+	dst.Close()
+	if err != nil {
+		os.Remove(target)
+		return fmt.Errorf("failed to verify %s: %w", target, err)
+	}
+
+	src.Close()
+	delete(openStores, path)
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".migrated"); err != nil {
+			return fmt.Errorf("failed to retire %s: %w", path, err)
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]any{"migrated": len(data.Entries), "to": target, "old": path + ".migrated"})
+	}
+	textf("Migrated %d entries to %s\n", len(data.Entries), target)
+	textf("The old data is kept in %s.migrated\n", path)
+	if dataFile != "" || (dataProfile == "" && os.Getenv("TIMETRACK_FILE") != "") {
+		textf("Point --file or TIMETRACK_FILE at %s from now on\n", target)
+	}
+	return nil
+}
@@ -5,22 +5,131 @@ import "time"
 type TimeEntry struct {
 	ID        string     `json:"id"`
 	Title     string     `json:"title"`
+	Project   string     `json:"project,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
 	StartTime time.Time  `json:"start_time"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
 	Notes     string     `json:"notes,omitempty"`
+	Billable  *bool      `json:"billable,omitempty"`
+	Breaks    []Break    `json:"breaks,omitempty"`
+
+	// PlannedEnd is set for timeboxed entries (start --for, pomodoro).
+	PlannedEnd *time.Time `json:"planned_end,omitempty"`
+
+	// AutoStopped marks entries stopped by the forgotten-timer cap rather
+	// than by the user. Editing the entry clears it.
+	AutoStopped bool `json:"auto_stopped,omitempty"`
+}
+
+// Break is a pause within an entry. End is nil while the entry is paused.
+type Break struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
 }
 
 type TimeData struct {
 	Entries []TimeEntry `json:"entries"`
+
+	// LastActivity is when the data was last saved, i.e. the last time the
+	// user did something; forgotten timers can be stopped there.
+	LastActivity time.Time `json:"last_activity,omitempty"`
 }
 
 func (e *TimeEntry) IsRunning() bool {
 	return e.EndTime == nil
 }
 
+func (e *TimeEntry) IsPaused() bool {
+	return e.IsRunning() && len(e.Breaks) > 0 && e.Breaks[len(e.Breaks)-1].End == nil
+}
+
+// Duration returns the time worked on the entry, excluding breaks.
 func (e *TimeEntry) Duration() time.Duration {
-	if e.EndTime == nil {
-		return time.Since(e.StartTime)
+	return e.DurationWithin(time.Time{}, time.Time{})
+}
+
+// BreakDuration returns the total time spent paused.
+func (e *TimeEntry) BreakDuration() time.Duration {
+	var total time.Duration
+	for _, b := range e.Breaks {
+		total += spanWithin(b.Start, b.endOr(time.Now()), time.Time{}, time.Time{})
+	}
+	return total
+}
+
+// Stop ends the entry at t, closing an open break and dropping any part of
+// a break that would fall after t.
+func (e *TimeEntry) Stop(t time.Time) {
+	e.EndTime = &t
+	breaks := e.Breaks[:0]
+	for _, b := range e.Breaks {
+		if !b.Start.Before(t) {
+			continue
+		}
+		if b.End == nil || b.End.After(t) {
+			end := t
+			b.End = &end
+		}
+		breaks = append(breaks, b)
+	}
+	e.Breaks = nil
+	if len(breaks) > 0 {
+		e.Breaks = breaks
+	}
+}
+
+func (b Break) endOr(now time.Time) time.Time {
+	if b.End == nil {
+		return now
 	}
-	return e.EndTime.Sub(e.StartTime)
+	return *b.End
+}
+
+func (e *TimeEntry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// DurationWithin returns the part of the entry's duration that falls inside
+// [start, end). A zero start or end leaves that side of the window open.
+// Breaks inside the window are not counted.
+func (e *TimeEntry) DurationWithin(start, end time.Time) time.Duration {
+	now := time.Now()
+	to := now
+	if e.EndTime != nil {
+		to = *e.EndTime
+	}
+	d := spanWithin(e.StartTime, to, start, end)
+	for _, b := range e.Breaks {
+		d -= spanWithin(b.Start, b.endOr(now), start, end)
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// spanWithin returns how much of [from, to) lies inside [start, end), with
+// zero bounds leaving that side of the window open.
+func spanWithin(from, to, start, end time.Time) time.Duration {
+	if !start.IsZero() && from.Before(start) {
+		from = start
+	}
+	if !end.IsZero() && to.After(end) {
+		to = end
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// IsBillable reports whether the entry should be invoiced. Entries are
+// billable unless marked otherwise.
+func (e *TimeEntry) IsBillable() bool {
+	return e.Billable == nil || *e.Billable
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// jsonOutput is set by --json or --format json. Commands then print one JSON
+// document on stdout instead of their text, and errors go to stderr as JSON.
+var jsonOutput bool
+
+// textOut and warningOut receive command output and warnings (including hook
+// output). The TUI points them at its status line.
+var (
+	textOut    io.Writer = os.Stdout
+	warningOut io.Writer = os.Stderr
+)
+
+// plainText reports whether commands print their usual text, rather than
+// JSON or a --format template.
+func plainText() bool {
+	return !jsonOutput && outputTemplate == nil
+}
+
+// textf prints human-readable output, which --json and --format templates
+// suppress.
+func textf(format string, args ...any) {
+	if plainText() {
+		fmt.Fprintf(textOut, format, args...)
+	}
+}
+
+// textln is the Println counterpart of textf.
+func textln(args ...any) {
+	if plainText() {
+		fmt.Fprintln(textOut, args...)
+	}
+}
+
+// printJSON writes a command's result when --json is set.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Error codes reported by --json, part of its stable schema.
+const (
+	codeUsage    = "usage"     // the command line was invalid
+	codeNotFound = "not_found" // an entry, backup or setting does not exist
+	codeConflict = "conflict"  // the change overlaps existing entries
+	codeStorage  = "storage"   // the data file could not be read or written
+	codeError    = "error"     // anything else
+)
+
+// codedError attaches an error code for --json output to an error.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+func withCode(code string, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// errorCode picks the --json error code for err.
+func errorCode(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	var pathErr *fs.PathError
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &pathErr) || errors.As(err, &syntaxErr) {
+		return codeStorage
+	}
+	return codeError
+}
+
+// exitWithError reports a failed command and exits non-zero.
+func exitWithError(code string, err error) {
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stderr)
+		enc.Encode(map[string]string{"error": err.Error(), "code": code})
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// usageError reports an invalid command line: the message and usage lines
+// on stdout, or a JSON error with code "usage" on stderr.
+func usageError(message string, usage ...string) {
+	if jsonOutput {
+		exitWithError(codeUsage, errors.New(message))
+	}
+	fmt.Println("Error: " + message)
+	for _, line := range usage {
+		fmt.Println(line)
+	}
+	os.Exit(1)
+}
+
+// outputFormat is the --format flag of commands without a format of their
+// own: text (the default), json, or for commands that allow it a template.
+type outputFormat struct {
+	command   string
+	templates bool
+}
+
+func (outputFormat) String() string {
+	return ""
+}
+
+func (o outputFormat) Set(value string) error {
+	jsonOutput, outputTemplate = false, nil
+	switch strings.ToLower(value) {
+	case "text":
+	case "json":
+		jsonOutput = true
+	default:
+		if !o.templates {
+			return fmt.Errorf("invalid format %q (expected text or json)", value)
+		}
+		tmpl, err := loadOutputTemplate(o.command, value)
+		if err != nil {
+			return err
+		}
+		outputTemplate = tmpl
+	}
+	return nil
+}
+
+// entryJSON is the --json shape of an entry: the export record plus the
+// state list, status and view show.
+type entryJSON struct {
+	exportRecord
+	Index       *int       `json:"index,omitempty"`
+	Billable    bool       `json:"billable"`
+	Paused      bool       `json:"paused,omitempty"`
+	AutoStopped bool       `json:"auto_stopped,omitempty"`
+	PlannedEnd  *time.Time `json:"planned_end,omitempty"`
+	Breaks      []Break    `json:"breaks,omitempty"`
+}
+
+// newEntryJSON returns the JSON shape of e, or nil for a nil entry so that
+// "no running task" encodes as null.
+func newEntryJSON(e *TimeEntry) *entryJSON {
+	if e == nil {
+		return nil
+	}
+	return &entryJSON{
+		exportRecord: newExportRecord(e),
+		Billable:     e.IsBillable(),
+		Paused:       e.IsPaused(),
+		AutoStopped:  e.AutoStopped,
+		PlannedEnd:   e.PlannedEnd,
+		Breaks:       e.Breaks,
+	}
+}
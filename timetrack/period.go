@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultWeekStart is the first day of the week for --week and friends,
+// overridable with TIMETRACK_WEEK_START, week_start in the config or
+// --week-start.
+func defaultWeekStart() time.Weekday {
+	if day, err := parseWeekday(os.Getenv("TIMETRACK_WEEK_START")); err == nil {
+		return day
+	}
+	if day, err := parseWeekday(configWeekStart); err == nil {
+		return day
+	}
+	return time.Monday
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	if day, ok := weekdays[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return day, nil
+	}
+	return time.Sunday, fmt.Errorf("invalid weekday %q", s)
+}
+
+// startOfWeek returns midnight of the first day of t's week.
+func startOfWeek(t time.Time, weekStart time.Weekday) time.Time {
+	back := (int(t.Weekday()) - int(weekStart) + 7) % 7
+	return startOfDay(t).AddDate(0, 0, -back)
+}
+
+// periodRange returns the [start, end) range and label of a named calendar
+// period. value optionally picks a specific week (2026-W41), month (2026-09)
+// or year (2025) instead of the current one.
+func periodRange(period, value string, now time.Time, weekStart time.Weekday) (time.Time, time.Time, string, error) {
+	today := startOfDay(now)
+	var zero time.Time
+
+	switch period {
+	case "today":
+		return today, today.AddDate(0, 0, 1), "Today", nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, "Yesterday", nil
+	case "week":
+		if value == "" {
+			start := startOfWeek(now, weekStart)
+			return start, start.AddDate(0, 0, 7), "This week", nil
+		}
+		start, err := parseISOWeek(value, now.Location())
+		if err != nil {
+			return zero, zero, "", err
+		}
+		// ISO weeks start on Monday; move back to the configured start day.
+		start = start.AddDate(0, 0, -((int(time.Monday) - int(weekStart) + 7) % 7))
+		return start, start.AddDate(0, 0, 7), "Week " + strings.ToUpper(value), nil
+	case "last-week":
+		start := startOfWeek(now, weekStart).AddDate(0, 0, -7)
+		return start, start.AddDate(0, 0, 7), "Last week", nil
+	case "month":
+		if value == "" {
+			start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+			return start, start.AddDate(0, 1, 0), "This month", nil
+		}
+		start, err := time.ParseInLocation("2006-01", value, now.Location())
+		if err != nil {
+			return zero, zero, "", fmt.Errorf("invalid month %q (expected YYYY-MM)", value)
+		}
+		return start, start.AddDate(0, 1, 0), start.Format("January 2006"), nil
+	case "last-month":
+		start := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0), "Last month (" + start.Format("January 2006") + ")", nil
+	case "year":
+		year := now.Year()
+		label := "This year"
+		if value != "" {
+			y, err := strconv.Atoi(value)
+			if err != nil {
+				return zero, zero, "", fmt.Errorf("invalid year %q", value)
+			}
+			year, label = y, value
+		}
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(1, 0, 0), label, nil
+	}
+	return zero, zero, "", fmt.Errorf("unknown period %q", period)
+}
+
+// parseISOWeek returns the Monday starting an ISO 8601 week such as 2026-W41.
+func parseISOWeek(s string, loc *time.Location) (time.Time, error) {
+	var year, week int
+	if _, err := fmt.Sscanf(strings.ToUpper(s), "%d-W%d", &year, &week); err != nil || week < 1 || week > 53 {
+		return time.Time{}, fmt.Errorf("invalid week %q (expected YYYY-Www, e.g. 2026-W41)", s)
+	}
+	// January 4th is always in week 1.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	week1 := startOfWeek(jan4, time.Monday)
+	start := week1.AddDate(0, 0, (week-1)*7)
+	if y, w := start.ISOWeek(); y != year || w != week {
+		return time.Time{}, fmt.Errorf("%d has no week %d", year, week)
+	}
+	return start, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"time"
+)
+
+const (
+	pomodoroTag      = "pomodoro"
+	pomodoroBreakTag = "pomodoro-break"
+)
+
+// PomodoroOptions configures a pomodoro session.
+type PomodoroOptions struct {
+	Work      time.Duration
+	Break     time.Duration
+	LongBreak time.Duration
+	LongEvery int // take a long break after this many rounds
+	Rounds    int // stop after this many rounds; 0 runs until interrupted
+}
+
+// isCompletedPomodoro reports whether e is a pomodoro work entry that ran to
+// its planned end.
+func isCompletedPomodoro(e *TimeEntry) bool {
+	return e.HasTag(pomodoroTag) && e.EndTime != nil && e.PlannedEnd != nil && !e.EndTime.Before(*e.PlannedEnd)
+}
+
+// RunPomodoro alternates timeboxed work and break entries in the foreground.
+// Each entry has a planned end, so if the process dies the next command
+// still stops it on time. Interrupting stops the current entry now.
+func RunPomodoro(title string, project string, opts PomodoroOptions) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	for round := 1; opts.Rounds == 0 || round <= opts.Rounds; round++ {
+		textf("\nRound %d: work for %s\n", round, formatDuration(opts.Work))
+		err := StartTask(title, StartOptions{Project: project, Tags: []string{pomodoroTag}, For: opts.Work})
+		if err != nil {
+			return err
+		}
+		if !waitTimebox(opts.Work, interrupt) {
+			return StopTask(time.Time{})
+		}
+		if err := stopExpiredTimebox(); err != nil {
+			return err
+		}
+
+		if opts.Rounds != 0 && round == opts.Rounds {
+			break
+		}
+
+		pause := opts.Break
+		if opts.LongEvery > 0 && round%opts.LongEvery == 0 {
+			pause = opts.LongBreak
+		}
+		textf("\nBreak for %s\n", formatDuration(pause))
+		err = StartTask("Break", StartOptions{Tags: []string{pomodoroBreakTag}, For: pause, NonBillable: true})
+		if err != nil {
+			return err
+		}
+		if !waitTimebox(pause, interrupt) {
+			return StopTask(time.Time{})
+		}
+		if err := stopExpiredTimebox(); err != nil {
+			return err
+		}
+	}
+
+	textln("\nPomodoro session complete")
+	return nil
+}
+
+// waitTimebox blocks for d, printing the time left each minute. It returns
+// false if interrupted.
+func waitTimebox(d time.Duration, interrupt <-chan os.Signal) bool {
+	end := time.Now().Add(d)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			textf("\a")
+			return true
+		case <-ticker.C:
+			textf("  %s left\n", formatDuration(time.Until(end).Round(time.Minute)))
+		case <-interrupt:
+			textln()
+			return false
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// daySpan is the part of an entry that falls on one calendar day.
+type daySpan struct {
+	Day      time.Time
+	Duration time.Duration
+}
+
+// splitByDay breaks an entry into per-day pieces at local midnight, clipped
+// to the window [start, end). Zero bounds leave that side open.
+func splitByDay(e *TimeEntry, start, end time.Time) []daySpan {
+	from := e.StartTime
+	if !start.IsZero() && from.Before(start) {
+		from = start
+	}
+	stop := time.Now()
+	if e.EndTime != nil {
+		stop = *e.EndTime
+	}
+	if !end.IsZero() && stop.After(end) {
+		stop = end
+	}
+
+	var spans []daySpan
+	for day := startOfDay(from.Local()); day.Before(stop); day = day.AddDate(0, 0, 1) {
+		if d := e.DurationWithin(maxTime(day, from), minTime(day.AddDate(0, 0, 1), stop)); d > 0 {
+			spans = append(spans, daySpan{Day: day, Duration: d})
+		}
+	}
+	return spans
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// summaryRow is one group in the breakdown section of a summary.
+type summaryRow struct {
+	Key      string
+	Duration time.Duration
+	First    time.Time // earliest start of an entry in the group
+	Last     time.Time // latest start of an entry in the group
+	Notes    []string
+	Days     map[string]time.Duration // per-day durations keyed by YYYY-MM-DD
+}
+
+// sortSummaryRows orders rows by duration (longest first, the default),
+// title, first or last use. Ties fall back to the key so output is stable.
+func sortSummaryRows(rows []summaryRow, by string) error {
+	var less func(a, b *summaryRow) bool
+	switch by {
+	case "", "duration":
+		less = func(a, b *summaryRow) bool { return a.Duration > b.Duration }
+	case "title":
+		less = func(a, b *summaryRow) bool { return strings.ToLower(a.Key) < strings.ToLower(b.Key) }
+	case "first":
+		less = func(a, b *summaryRow) bool { return a.First.Before(b.First) }
+	case "last":
+		less = func(a, b *summaryRow) bool { return a.Last.After(b.Last) }
+	default:
+		return fmt.Errorf("invalid sort %q (expected duration, title, first or last)", by)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := &rows[i], &rows[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Key < b.Key
+	})
+	return nil
+}
+
+// topSummaryRows keeps the first n rows and folds the rest into a single
+// "other" row. n <= 0 keeps everything.
+func topSummaryRows(rows []summaryRow, n int) []summaryRow {
+	if n <= 0 || len(rows) <= n {
+		return rows
+	}
+	other := summaryRow{Key: fmt.Sprintf("(%d others)", len(rows)-n), Days: make(map[string]time.Duration)}
+	for _, row := range rows[n:] {
+		other.Duration += row.Duration
+		for day, d := range row.Days {
+			other.Days[day] += d
+		}
+	}
+	return append(rows[:n:n], other)
+}
+
+// formatReportDuration shows d as decimal hours for billing, or in the
+// usual "1h 30m" form.
+func formatReportDuration(d time.Duration, decimal bool) string {
+	if decimal {
+		return fmt.Sprintf("%.2fh", d.Hours())
+	}
+	return formatDuration(d)
+}
+
+// percentOf returns d as a percentage of total.
+func percentOf(d, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(d) / float64(total) * 100
+}
+
+// maxGridDays caps the width of the timesheet grid.
+const maxGridDays = 31
+
+// printGrid prints the classic timesheet: one row per group, one column per
+// day in [start, end), with row and column totals.
+func printGrid(rows []summaryRow, start, end time.Time, decimal bool) {
+	var days []time.Time
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	width := len("TOTAL")
+	for _, row := range rows {
+		width = max(width, len(row.Key))
+	}
+	width = min(width, 30)
+
+	cell := func(d time.Duration) string {
+		if d == 0 {
+			return "-"
+		}
+		if decimal {
+			return fmt.Sprintf("%.2f", d.Hours())
+		}
+		return fmt.Sprintf("%d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+
+	textf("%-*s", width, "")
+	for _, day := range days {
+		textf(" %7s", day.Format("Mon 02"))
+	}
+	textf(" %8s\n", "TOTAL")
+	textln(strings.Repeat("-", width+8*len(days)+9))
+
+	dayTotals := make([]time.Duration, len(days))
+	var total time.Duration
+	for _, row := range rows {
+		key := row.Key
+		if len(key) > width {
+			key = key[:width-2] + ".."
+		}
+		textf("%-*s", width, key)
+		var rowTotal time.Duration
+		for i, day := range days {
+			d := row.Days[day.Format("2006-01-02")]
+			dayTotals[i] += d
+			rowTotal += d
+			textf(" %7s", cell(d))
+		}
+		total += rowTotal
+		textf(" %8s\n", cell(rowTotal))
+	}
+
+	textln(strings.Repeat("-", width+8*len(days)+9))
+	textf("%-*s", width, "TOTAL")
+	for _, d := range dayTotals {
+		textf(" %7s", cell(d))
+	}
+	textf(" %8s\n", cell(total))
+}
+
+// summaryJSON is the --json shape of a summary. From and To are null for
+// open-ended periods; Days lists the tracked days of bounded periods.
+type summaryJSON struct {
+	Period       string             `json:"period"`
+	From         *time.Time         `json:"from"`
+	To           *time.Time         `json:"to"`
+	GroupBy      string             `json:"group_by"`
+	TotalSeconds int64              `json:"total_seconds"`
+	Entries      int                `json:"entries"`
+	Pomodoros    int                `json:"pomodoros"`
+	Groups       []summaryGroupJSON `json:"groups"`
+	Days         []summaryDayJSON   `json:"days,omitempty"`
+}
+
+// summaryGroupJSON is one group of a summary, with seconds per day
+// (YYYY-MM-DD).
+type summaryGroupJSON struct {
+	Key     string           `json:"key"`
+	Seconds int64            `json:"seconds"`
+	Percent float64          `json:"percent"`
+	First   *time.Time       `json:"first,omitempty"`
+	Last    *time.Time       `json:"last,omitempty"`
+	Notes   []string         `json:"notes,omitempty"`
+	Days    map[string]int64 `json:"days,omitempty"`
+}
+
+type summaryDayJSON struct {
+	Date      string `json:"date"`
+	Seconds   int64  `json:"seconds"`
+	Pomodoros int    `json:"pomodoros,omitempty"`
+}
+
+func newSummaryGroupJSON(row summaryRow, total time.Duration) summaryGroupJSON {
+	g := summaryGroupJSON{
+		Key:     row.Key,
+		Seconds: int64(row.Duration.Seconds()),
+		Percent: math.Round(percentOf(row.Duration, total)*10) / 10,
+		Notes:   row.Notes,
+	}
+	// The merged "(N others)" row has no first or last entry
+	if !row.First.IsZero() {
+		g.First, g.Last = &row.First, &row.Last
+	}
+	if len(row.Days) > 0 {
+		g.Days = make(map[string]int64, len(row.Days))
+		for day, d := range row.Days {
+			g.Days[day] = int64(d.Seconds())
+		}
+	}
+	return g
+}
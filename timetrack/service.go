@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,6 +16,8 @@ func generateID() string {
 	if err != nil {
 		panic("Error with rand function")
 	}
+	// Start with a-f so a prefix of a new ID never reads as a list index
+	bytes[0] = 0xa0 + bytes[0]%0x60
 	return hex.EncodeToString(bytes)
 }
 
@@ -39,23 +42,224 @@ func getSortedIndices(entries []TimeEntry) []int {
 	return indices
 }
 
-func StartTask(title string) error {
+// StartOptions carries the optional metadata for a new entry. Values given
+// here are merged with any inline "@project" / "+tag" words in the title.
+type StartOptions struct {
+	Project     string
+	Tags        []string
+	At          time.Time     // backdated start; zero means now
+	For         time.Duration // timebox length; the entry stops on its own after it
+	NonBillable bool
+}
+
+// EntryFilter restricts which entries a listing shows. Empty fields match all.
+type EntryFilter struct {
+	Project     string
+	Tag         string
+	AutoStopped bool
+}
+
+func (f EntryFilter) Match(e *TimeEntry) bool {
+	if f.Project != "" && e.Project != f.Project {
+		return false
+	}
+	if f.Tag != "" && !e.HasTag(f.Tag) {
+		return false
+	}
+	if f.AutoStopped && !e.AutoStopped {
+		return false
+	}
+	return true
+}
+
+// parseInlineMeta pulls "@project" and "+tag" words out of a title, so
+// "Fix login +backend @acme" becomes "Fix login" with project "acme" and
+// tag "backend". Titles without such words are returned unchanged.
+func parseInlineMeta(s string) (title, project string, tags []string) {
+	var words []string
+	for _, word := range strings.Fields(s) {
+		switch {
+		case len(word) > 1 && word[0] == '@':
+			project = word[1:]
+		case len(word) > 1 && word[0] == '+':
+			tags = addTag(tags, word[1:])
+		default:
+			words = append(words, word)
+		}
+	}
+	if project == "" && len(tags) == 0 {
+		return s, "", nil
+	}
+	return strings.Join(words, " "), project, tags
+}
+
+// resolveMeta parses the inline metadata in title and merges in opts, with
+// an explicit project taking precedence over an inline one.
+func resolveMeta(title string, opts StartOptions) (string, string, []string) {
+	title, project, tags := parseInlineMeta(title)
+	if opts.Project != "" {
+		project = opts.Project
+	}
+	for _, tag := range opts.Tags {
+		tags = addTag(tags, tag)
+	}
+	return title, project, tags
+}
+
+func addTag(tags []string, tag string) []string {
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
+func removeTag(tags []string, tag string) []string {
+	result := tags[:0]
+	for _, t := range tags {
+		if t != tag {
+			result = append(result, t)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// formatMeta renders an entry's project and tags in the inline syntax,
+// with a leading space, or "" when it has neither.
+func formatMeta(e *TimeEntry) string {
+	var b strings.Builder
+	if e.Project != "" {
+		b.WriteString(" @" + e.Project)
+	}
+	for _, tag := range e.Tags {
+		b.WriteString(" +" + tag)
+	}
+	return b.String()
+}
+
+// minIDPrefix is the shortest number resolveEntry will also read as an ID
+// prefix; anything shorter is taken as an index.
+const minIDPrefix = 4
+
+// resolveEntry finds the entry referred to by ref and returns its position in
+// data.Entries. A ref is either an index into the list order (most recent
+// first) or an entry ID; like git, any unique prefix of an ID is accepted.
+// Short numbers are always indices. A number long enough to be an ID prefix
+// (minIDPrefix digits) that is both is rejected rather than guessed at;
+// "@N" always means index N.
+func resolveEntry(data *TimeData, ref string) (int, error) {
+	if ref == "" {
+		return -1, fmt.Errorf("missing entry reference")
+	}
+
+	for i := range data.Entries {
+		if data.Entries[i].ID == ref {
+			return i, nil
+		}
+	}
+
+	sortedIndices := getSortedIndices(data.Entries)
+	if rest, ok := strings.CutPrefix(ref, "@"); ok {
+		index, err := strconv.Atoi(rest)
+		if err != nil || index < 0 || index >= len(sortedIndices) {
+			return -1, withCode(codeNotFound, fmt.Errorf("invalid index: %s (valid range: 0-%d)", rest, len(sortedIndices)-1))
+		}
+		return sortedIndices[index], nil
+	}
+	if index, err := strconv.Atoi(ref); err == nil && index >= 0 && index < len(sortedIndices) {
+		for i := range data.Entries {
+			if len(ref) >= minIDPrefix && strings.HasPrefix(data.Entries[i].ID, ref) {
+				return -1, withCode(codeUsage, fmt.Errorf("ambiguous entry reference: %s is index %d and a prefix of ID %s (use more ID characters, or @%d for the index)",
+					ref, index, data.Entries[i].ID, index))
+			}
+		}
+		return sortedIndices[index], nil
+	}
+
+	match := -1
+	for i := range data.Entries {
+		if strings.HasPrefix(data.Entries[i].ID, ref) {
+			if match != -1 {
+				return -1, withCode(codeUsage, fmt.Errorf("ambiguous entry ID prefix: %s", ref))
+			}
+			match = i
+		}
+	}
+	if match == -1 {
+		if _, err := strconv.Atoi(ref); err == nil {
+			return -1, withCode(codeNotFound, fmt.Errorf("invalid index: %s (valid range: 0-%d)", ref, len(sortedIndices)-1))
+		}
+		return -1, withCode(codeNotFound, fmt.Errorf("no entry matches: %s", ref))
+	}
+	return match, nil
+}
+
+func StartTask(title string, opts StartOptions) error {
+	title, project, tags := resolveMeta(title, opts)
+	if title == "" {
+		return fmt.Errorf("task title cannot be empty")
+	}
+
+	now := time.Now()
+	startAt := now
+	if !opts.At.IsZero() {
+		if opts.At.After(now) {
+			return fmt.Errorf("start time %s is in the future", formatDateTime(opts.At))
+		}
+		startAt = opts.At
+	}
+
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
 
+	if prior, ok := canonicalTitle(data, title); ok && prior != title {
+		textf("Using existing title: %s\n", prior)
+		title = prior
+	} else if !ok {
+		if matches := matchTitles(data, title); len(matches) > 0 {
+			textf("New title (similar to earlier: %s)\n", matches[0])
+		}
+	}
+
 	running := findRunningTask(data)
+	if running != nil && startAt.Before(running.StartTime) {
+		return withCode(codeConflict, fmt.Errorf("start time would be before the running task started (%s)",
+			formatDateTime(running.StartTime)))
+	}
+	if other := findOverlap(data, startAt, now, running); other != nil {
+		return withCode(codeConflict, fmt.Errorf("overlaps with existing entry: %s [%s] started %s",
+			other.Title, other.ID, formatDateTime(other.StartTime)))
+	}
 	if running != nil {
-		now := time.Now()
-		running.EndTime = &now
-		fmt.Printf("Stopped: %s (ran for %s)\n", running.Title, formatDuration(running.Duration()))
+		running.Stop(startAt)
+		textf("Stopped: %s (ran for %s)\n", running.Title, formatDuration(running.Duration()))
 	}
 
 	entry := TimeEntry{
 		ID:        generateID(),
 		Title:     title,
-		StartTime: time.Now(),
+		Project:   project,
+		Tags:      tags,
+		StartTime: startAt,
+	}
+	if opts.NonBillable {
+		entry.Billable = new(bool)
+	}
+	if opts.For > 0 {
+		plannedEnd := startAt.Add(opts.For)
+		entry.PlannedEnd = &plannedEnd
 	}
 
 	data.Entries = append(data.Entries, entry)
@@ -63,12 +267,82 @@ func StartTask(title string) error {
 	if err := SaveData(data); err != nil {
 		return fmt.Errorf("failed to save data: %w", err)
 	}
+	if running != nil {
+		queueHook("stop", running)
+	}
+	queueHook("start", &entry)
 
-	fmt.Printf("Started: %s [%s]\n", title, entry.ID)
+	if jsonOutput {
+		return printJSON(map[string]any{"started": newEntryJSON(&entry), "stopped": newEntryJSON(running)})
+	}
+	textf("Started: %s%s [%s]\n", title, formatMeta(&entry), entry.ID)
+	if entry.PlannedEnd != nil {
+		textf("Timebox: until %s\n", formatClock(*entry.PlannedEnd))
+	}
+	return nil
+}
+
+// printNoChange reports a command that had nothing to do: the message as
+// text, or with --json a null result under key plus the message.
+func printNoChange(key, message string) error {
+	if jsonOutput {
+		return printJSON(map[string]any{key: nil, "message": message})
+	}
+	textln(message)
 	return nil
 }
 
-func StopTask() error {
+// ContinueTask starts a new entry with the title, project, tags and billing of
+// an earlier one: the entry ref refers to, or the most recently stopped one.
+func ContinueTask(ref string) error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	var source *TimeEntry
+	if ref != "" {
+		origIdx, err := resolveEntry(data, ref)
+		if err != nil {
+			return err
+		}
+		source = &data.Entries[origIdx]
+		if source.IsRunning() {
+			return printNoChange("started", "Already running: "+source.Title)
+		}
+	} else {
+		for _, idx := range getSortedIndices(data.Entries) {
+			if !data.Entries[idx].IsRunning() {
+				source = &data.Entries[idx]
+				break
+			}
+		}
+		if source == nil {
+			return printNoChange("started", "No stopped entry to continue")
+		}
+	}
+
+	return StartTask(source.Title, StartOptions{
+		Project:     source.Project,
+		Tags:        source.Tags,
+		NonBillable: !source.IsBillable(),
+	})
+}
+
+// StopTask stops the running task at the given time, or now if at is zero.
+func StopTask(at time.Time) error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
@@ -76,22 +350,40 @@ func StopTask() error {
 
 	running := findRunningTask(data)
 	if running == nil {
-		fmt.Println("No task is currently running")
-		return nil
+		return printNoChange("stopped", "No task is currently running")
 	}
 
 	now := time.Now()
-	running.EndTime = &now
+	if !at.IsZero() {
+		if at.After(now) {
+			return fmt.Errorf("stop time %s is in the future", formatDateTime(at))
+		}
+		if at.Before(running.StartTime) {
+			return fmt.Errorf("stop time would be before start time")
+		}
+		now = at
+	}
+	running.Stop(now)
 
 	if err := SaveData(data); err != nil {
 		return fmt.Errorf("failed to save data: %w", err)
 	}
+	queueHook("stop", running)
 
-	fmt.Printf("Stopped: %s (ran for %s)\n", running.Title, formatDuration(running.Duration()))
+	if jsonOutput {
+		return printJSON(map[string]any{"stopped": newEntryJSON(running)})
+	}
+	textf("Stopped: %s (ran for %s)\n", running.Title, formatDuration(running.Duration()))
 	return nil
 }
 
-func Status() error {
+func PauseTask() error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
@@ -99,44 +391,268 @@ func Status() error {
 
 	running := findRunningTask(data)
 	if running == nil {
-		fmt.Println("No task is currently running")
-		return nil
+		return printNoChange("paused", "No task is currently running")
+	}
+	if running.IsPaused() {
+		return printNoChange("paused", "Already paused: "+running.Title)
+	}
+
+	running.Breaks = append(running.Breaks, Break{Start: time.Now()})
+
+	if err := SaveData(data); err != nil {
+		return fmt.Errorf("failed to save data: %w", err)
 	}
 
-	fmt.Printf("Running: %s [%s]\n", running.Title, running.ID)
-	fmt.Printf("Started: %s (%s ago)\n", running.StartTime.Format("15:04:05"), formatDuration(running.Duration()))
+	if jsonOutput {
+		return printJSON(map[string]any{"paused": newEntryJSON(running)})
+	}
+	textf("Paused: %s (worked %s)\n", running.Title, formatDuration(running.Duration()))
 	return nil
 }
 
-func ListTasks(limit int) error {
+func ResumeTask() error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
 
-	if len(data.Entries) == 0 {
-		fmt.Println("No time entries found")
+	running := findRunningTask(data)
+	if running == nil {
+		return printNoChange("resumed", "No task is currently running")
+	}
+	if !running.IsPaused() {
+		return printNoChange("resumed", "Not paused: "+running.Title)
+	}
+
+	now := time.Now()
+	open := &running.Breaks[len(running.Breaks)-1]
+	open.End = &now
+
+	if err := SaveData(data); err != nil {
+		return fmt.Errorf("failed to save data: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]any{"resumed": newEntryJSON(running)})
+	}
+	textf("Resumed: %s (paused for %s)\n", running.Title, formatDuration(now.Sub(open.Start)))
+	return nil
+}
+
+// findOverlap returns the first entry other than skip whose time span
+// overlaps [start, end). Running entries are treated as ending now.
+func findOverlap(data *TimeData, start, end time.Time, skip *TimeEntry) *TimeEntry {
+	now := time.Now()
+	for i := range data.Entries {
+		entry := &data.Entries[i]
+		if entry == skip {
+			continue
+		}
+		entryEnd := now
+		if entry.EndTime != nil {
+			entryEnd = *entry.EndTime
+		}
+		if entry.StartTime.Before(end) && start.Before(entryEnd) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// LogTask records a finished entry after the fact, for work that was never
+// started with StartTask.
+func LogTask(title string, start, end time.Time, opts StartOptions) error {
+	title, project, tags := resolveMeta(title, opts)
+	if title == "" {
+		return fmt.Errorf("task title cannot be empty")
+	}
+
+	if end.Before(start) {
+		return fmt.Errorf("end time would be before start time")
+	}
+	if end.Equal(start) {
+		return fmt.Errorf("entry would have zero duration")
+	}
+	if end.After(time.Now()) {
+		return fmt.Errorf("end time %s is in the future", formatDateTime(end))
+	}
+
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	if other := findOverlap(data, start, end, nil); other != nil {
+		return withCode(codeConflict, fmt.Errorf("overlaps with existing entry: %s [%s] started %s",
+			other.Title, other.ID, formatDateTime(other.StartTime)))
+	}
+
+	entry := TimeEntry{
+		ID:        generateID(),
+		Title:     title,
+		Project:   project,
+		Tags:      tags,
+		StartTime: start,
+		EndTime:   &end,
+	}
+	if opts.NonBillable {
+		entry.Billable = new(bool)
+	}
+
+	data.Entries = append(data.Entries, entry)
+
+	if err := SaveData(data); err != nil {
+		return fmt.Errorf("failed to save data: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]any{"logged": newEntryJSON(&entry)})
+	}
+	textf("Logged: %s%s [%s] %s - %s (%s)\n", title, formatMeta(&entry), entry.ID,
+		formatDateTime(start), formatClock(end), formatDuration(entry.Duration()))
+	return nil
+}
+
+// statusJSON is the --json shape of status. Running is null when no task is
+// running; a paused task has "paused": true.
+type statusJSON struct {
+	Running            *entryJSON `json:"running"`
+	TodaySeconds       int64      `json:"today_seconds"`
+	DailyTargetSeconds int64      `json:"daily_target_seconds,omitempty"`
+}
+
+func Status() error {
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	running := findRunningTask(data)
+	if outputTemplate != nil {
+		view := statusView{Today: displayDuration(trackedOn(data, time.Now())), DailyTarget: displayDuration(dailyTarget)}
+		if running != nil {
+			view.entryView = newEntryView(running, 0)
+		}
+		return printTemplate(view)
+	}
+	if jsonOutput {
+		return printJSON(statusJSON{
+			Running:            newEntryJSON(running),
+			TodaySeconds:       int64(trackedOn(data, time.Now()).Seconds()),
+			DailyTargetSeconds: int64(dailyTarget.Seconds()),
+		})
+	}
+
+	switch {
+	case running == nil:
+		textln("No task is currently running")
+	case running.IsPaused():
+		pausedAt := running.Breaks[len(running.Breaks)-1].Start
+		textf("Paused: %s%s [%s]\n", running.Title, formatMeta(running), running.ID)
+		textf("Paused for %s (worked %s)\n", formatDuration(time.Since(pausedAt)), formatDuration(running.Duration()))
+	default:
+		textf("Running: %s%s [%s]\n", running.Title, formatMeta(running), running.ID)
+		textf("Started: %s (%s ago)\n", formatClockSeconds(running.StartTime), formatDuration(running.Duration()))
+		if running.PlannedEnd != nil {
+			textf("Timebox: %s remaining (ends %s)\n", formatDuration(time.Until(*running.PlannedEnd)), formatClock(*running.PlannedEnd))
+		}
+	}
+
+	if dailyTarget > 0 {
+		today := trackedOn(data, time.Now())
+		textf("Today:   %s of %s target (%.0f%%)\n", formatDuration(today), formatDuration(dailyTarget), percentOf(today, dailyTarget))
+	}
+	return nil
+}
+
+// trackedOn returns the time tracked on day's calendar day.
+func trackedOn(data *TimeData, day time.Time) time.Duration {
+	start := startOfDay(day)
+	end := start.AddDate(0, 0, 1)
+	var total time.Duration
+	for i := range data.Entries {
+		total += data.Entries[i].DurationWithin(start, end)
+	}
+	return total
+}
+
+func ListTasks(limit int, filter EntryFilter) error {
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	if len(data.Entries) == 0 && plainText() {
+		textln("No time entries found")
 		return nil
 	}
 
-	// Get indices sorted by start time, most recent first
+	// Get indices sorted by start time, most recent first. Positions in this
+	// order are the indices other commands accept, so keep them when filtering.
 	sortedIndices := getSortedIndices(data.Entries)
+	var positions []int
+	for i, origIdx := range sortedIndices {
+		if filter.Match(&data.Entries[origIdx]) {
+			positions = append(positions, i)
+		}
+	}
+
+	if len(positions) == 0 && plainText() {
+		textln("No time entries match the filter")
+		return nil
+	}
 
 	// Apply limit
-	totalEntries := len(sortedIndices)
-	displayIndices := sortedIndices
+	totalEntries := len(positions)
+	displayPositions := positions
 	if limit > 0 && limit < totalEntries {
-		displayIndices = sortedIndices[:limit]
+		displayPositions = positions[:limit]
 	}
 
-	fmt.Printf("%-5s %-30s %-20s %-20s %-10s\n", "IDX", "TITLE", "START", "END", "DURATION")
-	fmt.Println(strings.Repeat("-", 90))
+	if outputTemplate != nil {
+		for _, i := range displayPositions {
+			if err := printTemplate(newEntryView(&data.Entries[sortedIndices[i]], i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if jsonOutput {
+		entries := make([]*entryJSON, 0, len(displayPositions))
+		for _, i := range displayPositions {
+			e := newEntryJSON(&data.Entries[sortedIndices[i]])
+			e.Index = &i
+			entries = append(entries, e)
+		}
+		return printJSON(map[string]any{"entries": entries, "total": totalEntries})
+	}
 
-	for i, origIdx := range displayIndices {
-		entry := data.Entries[origIdx]
+	textf("%-5s %-9s %-30s %-12s %-20s %-20s %-10s\n", "IDX", "ID", "TITLE", "PROJECT", "START", "END", "DURATION")
+	textln(strings.Repeat("-", 113))
+
+	for _, i := range displayPositions {
+		entry := data.Entries[sortedIndices[i]]
 		endStr := "running"
+		if entry.IsPaused() {
+			endStr = "paused"
+		}
 		if entry.EndTime != nil {
-			endStr = entry.EndTime.Format("2006-01-02 15:04")
+			endStr = formatDateTime(*entry.EndTime)
+		}
+		if entry.AutoStopped {
+			endStr += " *"
 		}
 
 		title := entry.Title
@@ -144,149 +660,291 @@ func ListTasks(limit int) error {
 			title = title[:28] + ".."
 		}
 
-		fmt.Printf("%-5d %-30s %-20s %-20s %-10s\n",
+		project := entry.Project
+		if len(project) > 12 {
+			project = project[:10] + ".."
+		}
+
+		textf("%-5d %-9s %-30s %-12s %-20s %-20s %-10s\n",
 			i,
+			entry.ID,
 			title,
-			entry.StartTime.Format("2006-01-02 15:04"),
+			project,
+			formatDateTime(entry.StartTime),
 			endStr,
 			formatDuration(entry.Duration()),
 		)
 	}
 
+	for _, i := range displayPositions {
+		if data.Entries[sortedIndices[i]].AutoStopped {
+			textln("\n* auto-stopped, check the end time and edit the entry to confirm it")
+			break
+		}
+	}
+
 	if limit > 0 && totalEntries > limit {
-		fmt.Printf("\nShowing %d of %d entries. Use -n <number> to show more.\n", limit, totalEntries)
+		textf("\nShowing %d of %d entries. Use -n <number> to show more.\n", limit, totalEntries)
 	}
 
 	return nil
 }
 
-func ViewTask(index int) error {
+func ViewTask(ref string) error {
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
 
 	if len(data.Entries) == 0 {
-		fmt.Println("No time entries found")
+		if !plainText() {
+			return withCode(codeNotFound, fmt.Errorf("no time entries found"))
+		}
+		textln("No time entries found")
 		return nil
 	}
 
-	sortedIndices := getSortedIndices(data.Entries)
+	origIdx, err := resolveEntry(data, ref)
+	if err != nil {
+		return err
+	}
+	entry := data.Entries[origIdx]
 
-	if index < 0 || index >= len(sortedIndices) {
-		return fmt.Errorf("invalid index: %d (valid range: 0-%d)", index, len(sortedIndices)-1)
+	index := 0
+	for i, idx := range getSortedIndices(data.Entries) {
+		if idx == origIdx {
+			index = i
+		}
 	}
 
-	origIdx := sortedIndices[index]
-	entry := data.Entries[origIdx]
+	if outputTemplate != nil {
+		return printTemplate(newEntryView(&entry, index))
+	}
+	if jsonOutput {
+		e := newEntryJSON(&entry)
+		e.Index = &index
+		return printJSON(map[string]any{"entry": e})
+	}
 
 	endStr := "running"
 	if entry.EndTime != nil {
-		endStr = entry.EndTime.Format("2006-01-02 15:04:05")
+		endStr = formatDateTimeSeconds(*entry.EndTime)
 	}
 
-	fmt.Printf("Index:    %d\n", index)
-	fmt.Printf("ID:       %s\n", entry.ID)
-	fmt.Printf("Title:    %s\n", entry.Title)
-	fmt.Printf("Start:    %s\n", entry.StartTime.Format("2006-01-02 15:04:05"))
-	fmt.Printf("End:      %s\n", endStr)
-	fmt.Printf("Duration: %s\n", formatDuration(entry.Duration()))
+	textf("Index:    %d\n", index)
+	textf("ID:       %s\n", entry.ID)
+	textf("Title:    %s\n", entry.Title)
+	if entry.Project != "" {
+		textf("Project:  %s\n", entry.Project)
+	}
+	if len(entry.Tags) > 0 {
+		textf("Tags:     %s\n", strings.Join(entry.Tags, ", "))
+	}
+	textf("Start:    %s\n", formatDateTimeSeconds(entry.StartTime))
+	textf("End:      %s\n", endStr)
+	if entry.AutoStopped {
+		textln("          (auto-stopped, edit the entry to confirm or correct it)")
+	}
+	textf("Duration: %s\n", formatDuration(entry.Duration()))
+	if len(entry.Breaks) > 0 {
+		textf("Breaks:   %s total\n", formatDuration(entry.BreakDuration()))
+		for _, b := range entry.Breaks {
+			if b.End == nil {
+				textf("  %s - (paused)\n", formatClockSeconds(b.Start))
+				continue
+			}
+			textf("  %s - %s (%s)\n", formatClockSeconds(b.Start), formatClockSeconds(*b.End), formatDuration(b.End.Sub(b.Start)))
+		}
+	}
+	if !entry.IsBillable() {
+		textln("Billable: no")
+	}
 	if entry.Notes != "" {
-		fmt.Printf("Notes:\n%s\n", entry.Notes)
+		textf("Notes:\n%s\n", entry.Notes)
 	}
 
 	return nil
 }
 
-func DeleteTask(index int) error {
+func DeleteTask(ref string) error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
 
-	if index < 0 || index >= len(data.Entries) {
-		return fmt.Errorf("invalid index: %d", index)
+	origIdx, err := resolveEntry(data, ref)
+	if err != nil {
+		return err
 	}
 
-	// Get the original index from sorted order (most recent first)
-	sortedIndices := getSortedIndices(data.Entries)
-	origIdx := sortedIndices[index]
-
-	fmt.Printf("Deleted: %s\n", data.Entries[origIdx].Title)
+	deleted := data.Entries[origIdx]
+	textf("Deleted: %s\n", deleted.Title)
 	data.Entries = append(data.Entries[:origIdx], data.Entries[origIdx+1:]...)
 
 	if err := SaveData(data); err != nil {
 		return fmt.Errorf("failed to save data: %w", err)
 	}
 
+	if jsonOutput {
+		return printJSON(map[string]any{"deleted": newEntryJSON(&deleted)})
+	}
 	return nil
 }
 
-func EditTask(index int, newTitle string, startAdjustMins int, endAdjustMins int) error {
+// EditOptions describes the changes to apply to an entry. Zero values leave
+// the corresponding field untouched; Project is a pointer so it can be cleared.
+// Start and End are either minute offsets ("-30") or time expressions
+// ("09:05", "2026-10-16 17:30"), see resolveTimeArg.
+type EditOptions struct {
+	Title    string
+	Project  *string
+	Tags     []string
+	Untags   []string
+	Start    string
+	End      string
+	Billable *bool
+}
+
+func EditTask(ref string, opts EditOptions) error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
 
-	if index < 0 || index >= len(data.Entries) {
-		return fmt.Errorf("invalid index: %d", index)
+	origIdx, err := resolveEntry(data, ref)
+	if err != nil {
+		return err
 	}
 
-	// Get the original index from sorted order (most recent first)
-	sortedIndices := getSortedIndices(data.Entries)
-	origIdx := sortedIndices[index]
-
 	entry := &data.Entries[origIdx]
 
+	newTitle, inlineProject, inlineTags := parseInlineMeta(opts.Title)
 	if newTitle != "" {
 		oldTitle := entry.Title
 		entry.Title = newTitle
-		fmt.Printf("Updated title: '%s' -> '%s'\n", oldTitle, newTitle)
+		textf("Updated title: '%s' -> '%s'\n", oldTitle, newTitle)
+	}
+
+	if opts.Project != nil {
+		inlineProject = *opts.Project
+	}
+	if opts.Project != nil || inlineProject != "" {
+		oldProject := entry.Project
+		entry.Project = inlineProject
+		textf("Updated project: '%s' -> '%s'\n", oldProject, entry.Project)
+	}
+
+	if len(inlineTags) > 0 || len(opts.Tags) > 0 || len(opts.Untags) > 0 {
+		for _, tag := range append(inlineTags, opts.Tags...) {
+			entry.Tags = addTag(entry.Tags, tag)
+		}
+		for _, tag := range opts.Untags {
+			entry.Tags = removeTag(entry.Tags, tag)
+		}
+		textf("Updated tags: %s\n", strings.Join(entry.Tags, ", "))
 	}
 
-	if startAdjustMins != 0 {
-		oldStart := entry.StartTime
-		entry.StartTime = entry.StartTime.Add(time.Duration(startAdjustMins) * time.Minute)
-		if entry.EndTime != nil && entry.StartTime.After(*entry.EndTime) {
-			entry.StartTime = oldStart
-			return fmt.Errorf("adjusted start time would be after end time")
+	if opts.Billable != nil {
+		entry.Billable = nil
+		if !*opts.Billable {
+			entry.Billable = opts.Billable
 		}
-		fmt.Printf("Updated start: %s -> %s\n", oldStart.Format("15:04"), entry.StartTime.Format("15:04"))
+		textf("Updated billable: %t\n", entry.IsBillable())
 	}
 
-	if endAdjustMins != 0 {
+	now := time.Now()
+	newStart := entry.StartTime
+	if opts.Start != "" {
+		if newStart, err = resolveTimeArg(opts.Start, entry.StartTime, now); err != nil {
+			return err
+		}
+		if newStart.After(now) {
+			return fmt.Errorf("start time %s is in the future", formatDateTime(newStart))
+		}
+	}
+
+	newEnd := entry.EndTime
+	if opts.End != "" {
 		if entry.EndTime == nil {
 			return fmt.Errorf("cannot adjust end time for a running task")
 		}
-		oldEnd := *entry.EndTime
-		newEnd := entry.EndTime.Add(time.Duration(endAdjustMins) * time.Minute)
-		if newEnd.Before(entry.StartTime) {
+		end, err := resolveTimeArg(opts.End, *entry.EndTime, now)
+		if err != nil {
+			return err
+		}
+		if end.After(now) {
+			return fmt.Errorf("end time %s is in the future", formatDateTime(end))
+		}
+		newEnd = &end
+	}
+
+	if newEnd != nil && newEnd.Before(newStart) {
+		if opts.End != "" {
 			return fmt.Errorf("adjusted end time would be before start time")
 		}
-		entry.EndTime = &newEnd
-		fmt.Printf("Updated end: %s -> %s\n", oldEnd.Format("15:04"), newEnd.Format("15:04"))
+		return fmt.Errorf("adjusted start time would be after end time")
+	}
+
+	if opts.Start != "" {
+		textf("Updated start: %s -> %s\n", formatEditTime(entry.StartTime, newStart), formatEditTime(newStart, entry.StartTime))
+		entry.StartTime = newStart
 	}
+	if opts.End != "" {
+		textf("Updated end: %s -> %s\n", formatEditTime(*entry.EndTime, *newEnd), formatEditTime(*newEnd, *entry.EndTime))
+		entry.EndTime = newEnd
+	}
+
+	// Any edit counts as reviewing an auto-stopped entry
+	entry.AutoStopped = false
 
 	if err := SaveData(data); err != nil {
 		return fmt.Errorf("failed to save data: %w", err)
 	}
 
+	if jsonOutput {
+		return printJSON(map[string]any{"entry": newEntryJSON(entry)})
+	}
 	return nil
 }
 
-func NoteTask(index int, note string) error {
+// formatEditTime shows t as a clock time, adding the date when it falls on a
+// different day from other.
+func formatEditTime(t, other time.Time) string {
+	if startOfDay(t).Equal(startOfDay(other)) {
+		return formatClock(t)
+	}
+	return formatDateTime(t)
+}
+
+func NoteTask(ref string, note string) error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := LoadData()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
 
-	if index < 0 || index >= len(data.Entries) {
-		return fmt.Errorf("invalid index: %d", index)
+	origIdx, err := resolveEntry(data, ref)
+	if err != nil {
+		return err
 	}
 
-	sortedIndices := getSortedIndices(data.Entries)
-	origIdx := sortedIndices[index]
-
 	entry := &data.Entries[origIdx]
 
 	if entry.Notes == "" {
@@ -299,86 +957,271 @@ func NoteTask(index int, note string) error {
 		return fmt.Errorf("failed to save data: %w", err)
 	}
 
-	fmt.Printf("Added note to: %s\n", entry.Title)
+	if jsonOutput {
+		return printJSON(map[string]any{"entry": newEntryJSON(entry)})
+	}
+	textf("Added note to: %s\n", entry.Title)
 	return nil
 }
 
-func Summary(filter string) error {
-	data, err := LoadData()
-	if err != nil {
-		return fmt.Errorf("failed to load data: %w", err)
+// summaryGroups returns the keys an entry is counted under when grouping a
+// summary. Grouping by tag counts an entry once for each of its tags.
+func summaryGroups(e *TimeEntry, groupBy string) []string {
+	switch groupBy {
+	case "project":
+		if e.Project == "" {
+			return []string{"(no project)"}
+		}
+		return []string{e.Project}
+	case "tag":
+		if len(e.Tags) == 0 {
+			return []string{"(untagged)"}
+		}
+		return e.Tags
+	}
+	return []string{e.Title}
+}
+
+// SummaryOptions selects the entries and grouping of a summary. From/To take
+// precedence over Period; leaving all of them empty summarises all time.
+type SummaryOptions struct {
+	Period      string // today, yesterday, week, last-week, month, last-month, year or last
+	PeriodValue string // specific week, month or year for Period, see periodRange
+	From        time.Time
+	To          time.Time
+	WeekStart   time.Weekday
+	GroupBy     string
+	Sort        string // duration (default), title, first or last
+	Top         int    // show only the top N groups plus an "other" row
+	Decimal     bool   // show decimal hours instead of "1h 30m"
+	Grid        bool   // show a timesheet grid of groups by day
+}
+
+func Summary(opts SummaryOptions) error {
+	groupBy := opts.GroupBy
+	if opts.Top < 0 {
+		return fmt.Errorf("invalid top %d (must be positive)", opts.Top)
 	}
 
-	if len(data.Entries) == 0 {
-		fmt.Println("No time entries found")
-		return nil
+	switch groupBy {
+	case "", "title":
+		groupBy = "title"
+	case "project", "tag":
+	default:
+		return fmt.Errorf("invalid group %q (expected project, tag or title)", groupBy)
 	}
 
 	now := time.Now()
 	var startFilter, endFilter time.Time
 	filterLabel := "All time"
-
-	switch filter {
-	case "today":
-		startFilter = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		filterLabel = "Today"
-	case "week":
-		weekday := int(now.Weekday())
-		if weekday == 0 {
-			weekday = 7
-		}
-		startFilter = time.Date(now.Year(), now.Month(), now.Day()-weekday+1, 0, 0, 0, 0, now.Location())
-		filterLabel = "This week"
-	case "last":
-		lastDay := findLastWorkingDay(data.Entries, now)
+	var err error
+
+	switch {
+	case !opts.From.IsZero() || !opts.To.IsZero():
+		startFilter, endFilter = opts.From, opts.To
+		switch {
+		case opts.From.IsZero():
+			filterLabel = "Until " + formatDateTime(opts.To)
+		case opts.To.IsZero():
+			filterLabel = "Since " + formatDateTime(opts.From)
+		default:
+			filterLabel = formatDateTime(opts.From) + " to " + formatDateTime(opts.To)
+		}
+	case opts.Period == "":
+	case opts.Period == "last":
+		all, err := LoadData()
+		if err != nil {
+			return fmt.Errorf("failed to load data: %w", err)
+		}
+		lastDay := findLastWorkingDay(all.Entries, now)
 		if lastDay.IsZero() {
-			fmt.Println("No entries found before today")
-			return nil
+			return printNoChange("summary", "No entries found before today")
 		}
 		startFilter = lastDay
 		endFilter = lastDay.AddDate(0, 0, 1)
 		filterLabel = "Last working day (" + lastDay.Format("Mon 2 Jan") + ")"
+	default:
+		startFilter, endFilter, filterLabel, err = periodRange(opts.Period, opts.PeriodValue, now, opts.WeekStart)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Grid {
+		if startFilter.IsZero() || endFilter.IsZero() {
+			return fmt.Errorf("--grid needs a period such as --week or --from/--to")
+		}
+		if endFilter.Sub(startFilter) > maxGridDays*24*time.Hour {
+			return fmt.Errorf("--grid supports periods of up to %d days", maxGridDays)
+		}
+	}
+
+	data, err := LoadDataBetween(startFilter, endFilter)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	if len(data.Entries) == 0 && startFilter.IsZero() && endFilter.IsZero() && plainText() {
+		textln("No time entries found")
+		return nil
 	}
 
 	var totalDuration time.Duration
-	taskDurations := make(map[string]time.Duration)
-	taskNotes := make(map[string][]string)
+	groups := make(map[string]*summaryRow)
+	dayDurations := make(map[string]time.Duration)
+	dayPomodoros := make(map[string]int)
+	pomodoros := 0
 	count := 0
 
-	for _, entry := range data.Entries {
-		if !startFilter.IsZero() && entry.StartTime.Before(startFilter) {
+	// Entries that cross the edges of the period only count for the part
+	// inside it, so a 22:00-02:00 session is shared between two days.
+	sortedIndices := getSortedIndices(data.Entries)
+	for i := len(sortedIndices) - 1; i >= 0; i-- {
+		entry := data.Entries[sortedIndices[i]]
+		duration := entry.DurationWithin(startFilter, endFilter)
+		if duration == 0 {
 			continue
 		}
-		if !endFilter.IsZero() && !entry.StartTime.Before(endFilter) {
-			continue
+		spans := splitByDay(&entry, startFilter, endFilter)
+		for _, span := range spans {
+			dayDurations[span.Day.Format("2006-01-02")] += span.Duration
+		}
+		if isCompletedPomodoro(&entry) {
+			dayPomodoros[entry.StartTime.Local().Format("2006-01-02")]++
+			pomodoros++
 		}
-		duration := entry.Duration()
 		totalDuration += duration
-		taskDurations[entry.Title] += duration
-		if entry.Notes != "" {
-			taskNotes[entry.Title] = append(taskNotes[entry.Title], entry.Notes)
+		for _, key := range summaryGroups(&entry, groupBy) {
+			row, ok := groups[key]
+			if !ok {
+				row = &summaryRow{Key: key, First: entry.StartTime, Days: make(map[string]time.Duration)}
+				groups[key] = row
+			}
+			row.Duration += duration
+			for _, span := range spans {
+				row.Days[span.Day.Format("2006-01-02")] += span.Duration
+			}
+			row.Last = entry.StartTime
+			if entry.Notes != "" {
+				row.Notes = append(row.Notes, entry.Notes)
+			}
 		}
 		count++
 	}
 
-	if count == 0 {
-		fmt.Println("No entries found for the selected period")
+	if count == 0 && plainText() {
+		textln("No entries found for the selected period")
 		return nil
 	}
 
-	fmt.Printf("=== %s Summary ===\n\n", filterLabel)
-	fmt.Printf("Total time: %s (%d entries)\n\n", formatDuration(totalDuration), count)
+	rows := make([]summaryRow, 0, len(groups))
+	for _, row := range groups {
+		rows = append(rows, *row)
+	}
+	if err := sortSummaryRows(rows, opts.Sort); err != nil {
+		return err
+	}
+	rows = topSummaryRows(rows, opts.Top)
+
+	if outputTemplate != nil {
+		view := summaryView{
+			Period:    filterLabel,
+			From:      startFilter,
+			To:        endFilter,
+			GroupBy:   groupBy,
+			Total:     displayDuration(totalDuration),
+			Entries:   count,
+			Pomodoros: pomodoros,
+		}
+		for _, row := range rows {
+			view.Groups = append(view.Groups, summaryGroupView{
+				Key:      row.Key,
+				Duration: displayDuration(row.Duration),
+				Percent:  percentOf(row.Duration, totalDuration),
+				First:    row.First,
+				Last:     row.Last,
+				Notes:    row.Notes,
+			})
+		}
+		if !startFilter.IsZero() && !endFilter.IsZero() {
+			for day := startFilter; day.Before(endFilter); day = day.AddDate(0, 0, 1) {
+				if d, ok := dayDurations[day.Format("2006-01-02")]; ok {
+					view.Days = append(view.Days, summaryDayView{Date: day, Duration: displayDuration(d), Pomodoros: dayPomodoros[day.Format("2006-01-02")]})
+				}
+			}
+		}
+		return printTemplate(view)
+	}
+	if jsonOutput {
+		out := summaryJSON{
+			Period:       filterLabel,
+			GroupBy:      groupBy,
+			TotalSeconds: int64(totalDuration.Seconds()),
+			Entries:      count,
+			Pomodoros:    pomodoros,
+			Groups:       make([]summaryGroupJSON, 0, len(rows)),
+		}
+		if !startFilter.IsZero() {
+			out.From = &startFilter
+		}
+		if !endFilter.IsZero() {
+			out.To = &endFilter
+		}
+		for _, row := range rows {
+			out.Groups = append(out.Groups, newSummaryGroupJSON(row, totalDuration))
+		}
+		if !startFilter.IsZero() && !endFilter.IsZero() {
+			for day := startFilter; day.Before(endFilter); day = day.AddDate(0, 0, 1) {
+				key := day.Format("2006-01-02")
+				if d, ok := dayDurations[key]; ok {
+					out.Days = append(out.Days, summaryDayJSON{Date: key, Seconds: int64(d.Seconds()), Pomodoros: dayPomodoros[key]})
+				}
+			}
+		}
+		return printJSON(out)
+	}
 
-	fmt.Println("By task:")
-	fmt.Println(strings.Repeat("-", 50))
+	textf("=== %s Summary ===\n\n", filterLabel)
+	textf("Total time: %s (%d entries)\n", formatReportDuration(totalDuration, opts.Decimal), count)
+	if pomodoros > 0 {
+		textf("Pomodoros: %d completed\n", pomodoros)
+	}
+	textln()
 
-	for title, duration := range taskDurations {
-		fmt.Printf("%s: %s\n", title, formatDuration(duration))
-		if notes, ok := taskNotes[title]; ok {
-			for _, note := range notes {
-				for _, line := range strings.Split(note, "\n") {
-					fmt.Printf("  - %s\n", line)
+	if opts.Grid {
+		printGrid(rows, startFilter, endFilter, opts.Decimal)
+		return nil
+	}
+
+	// Daily totals for bounded periods of up to a month
+	if !startFilter.IsZero() && !endFilter.IsZero() && len(dayDurations) > 1 && endFilter.Sub(startFilter) <= 31*24*time.Hour {
+		textln("By day:")
+		textln(strings.Repeat("-", 50))
+		for day := startFilter; day.Before(endFilter); day = day.AddDate(0, 0, 1) {
+			key := day.Format("2006-01-02")
+			if d, ok := dayDurations[key]; ok {
+				textf("%s: %s", day.Format("Mon 2 Jan"), formatReportDuration(d, opts.Decimal))
+				if n := dayPomodoros[key]; n > 0 {
+					textf(" (%d pomodoros)", n)
 				}
+				textln()
+			}
+		}
+		textln()
+	}
+
+	if groupBy == "title" {
+		textln("By task:")
+	} else {
+		textf("By %s:\n", groupBy)
+	}
+	textln(strings.Repeat("-", 50))
+
+	for _, row := range rows {
+		textf("%s: %s (%.1f%%)\n", row.Key, formatReportDuration(row.Duration, opts.Decimal), percentOf(row.Duration, totalDuration))
+		for _, note := range row.Notes {
+			for _, line := range strings.Split(note, "\n") {
+				textf("  - %s\n", line)
 			}
 		}
 	}
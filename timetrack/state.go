@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runState is the small cache status --short reads instead of the data
+// file, so a shell prompt never parses the full history. SaveData rewrites
+// it on every save.
+type runState struct {
+	Running *TimeEntry `json:"running"`
+	Updated time.Time  `json:"updated"`
+}
+
+// getStatePath returns the state file kept next to the data file.
+func getStatePath(dataPath string) string {
+	return strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + ".state"
+}
+
+// saveState records the running entry of data in the state file. It is only
+// a cache: when it cannot be written it is removed, and readers fall back to
+// the data file.
+func saveState(data *TimeData) {
+	path, err := getDataFilePath()
+	if err != nil {
+		return
+	}
+	statePath := getStatePath(path)
+	raw, err := json.Marshal(runState{Running: findRunningTask(data), Updated: data.LastActivity})
+	if err != nil || writeFileAtomic(statePath, raw) != nil {
+		os.Remove(statePath)
+	}
+}
+
+// loadRunningState returns the running entry from the state file. The file
+// is rebuilt from the data when it is missing, unreadable or older than the
+// data file (e.g. after restore-backup or a hand edit).
+func loadRunningState() (*TimeEntry, error) {
+	path, err := getDataFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	statePath := getStatePath(path)
+	if stateInfo, err := os.Stat(statePath); err == nil {
+		dataInfo, err := os.Stat(path)
+		if err != nil || !dataInfo.ModTime().After(stateInfo.ModTime()) {
+			var state runState
+			if raw, err := os.ReadFile(statePath); err == nil && json.Unmarshal(raw, &state) == nil {
+				return state.Running, nil
+			}
+		}
+	}
+
+	data, err := LoadData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	saveState(data)
+	return findRunningTask(data), nil
+}
+
+// ShortStatus prints the running task on one line for shell prompts and
+// status bars, e.g. "⏱ Fix login 1h12m", and nothing when idle. It skips the
+// auto-stop checks of other commands, treating an expired timebox as idle.
+func ShortStatus() error {
+	running, err := loadRunningState()
+	if err != nil {
+		return err
+	}
+	if running != nil && running.PlannedEnd != nil && !running.PlannedEnd.After(time.Now()) {
+		running = nil
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]any{"running": newEntryJSON(running)})
+	}
+	if running == nil {
+		return nil
+	}
+
+	icon := "⏱"
+	if running.IsPaused() {
+		icon = "⏸"
+	}
+	textf("%s %s %s\n", icon, running.Title, formatShortDuration(running.Duration()))
+	return nil
+}
+
+// formatShortDuration formats d compactly for prompts: "1h12m", "7m".
+func formatShortDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	if h := int(d.Hours()); h > 0 {
+		return fmt.Sprintf("%dh%02dm", h, int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
@@ -1,17 +1,95 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
+// maxBackups is how many earlier versions of the data file SaveData keeps.
+const maxBackups = 10
+
+// dataFile and dataProfile are set from the global --file and --profile
+// flags and take precedence over TIMETRACK_FILE and TIMETRACK_PROFILE.
+var dataFile, dataProfile string
+
+// getDataDir returns the directory for data files, following the XDG base
+// directory spec ($XDG_DATA_HOME/timetrack or ~/.local/share/timetrack).
+func getDataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "timetrack"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "timetrack"), nil
+}
+
+// getDataFilePath returns the data file of the active store. An explicit
+// file (--file, $TIMETRACK_FILE) is used as given; a profile (--profile,
+// $TIMETRACK_PROFILE) gets its own file under the data directory. Otherwise
+// the legacy ~/.timetrack.json is kept while it exists, and new installs use
+// the data directory.
 func getDataFilePath() (string, error) {
+	file, profile := dataFile, dataProfile
+	if file == "" && profile == "" {
+		file, profile = os.Getenv("TIMETRACK_FILE"), os.Getenv("TIMETRACK_PROFILE")
+	}
+	if file != "" {
+		return file, nil
+	}
+
+	dir, err := getDataDir()
+	if err != nil {
+		return "", err
+	}
+	if profile != "" {
+		if profile != filepath.Base(profile) || strings.HasPrefix(profile, ".") {
+			return "", fmt.Errorf("invalid profile name %q", profile)
+		}
+		return findDataFile(filepath.Join(dir, "profiles", profile)), nil
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".timetrack.json"), nil
+	legacy := findDataFile(filepath.Join(home, ".timetrack"))
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy, nil
+	}
+	return findDataFile(filepath.Join(dir, "timetrack")), nil
+}
+
+// findDataFile picks the data file for a path without extension: the SQLite
+// database once "migrate --to sqlite" has created one, otherwise the JSON
+// file.
+func findDataFile(base string) string {
+	if _, err := os.Stat(base + ".db"); err == nil {
+		return base + ".db"
+	}
+	return base + ".json"
+}
+
+// ensureDataDir creates the directory holding the data file, which new
+// installs and profiles do not have yet.
+func ensureDataDir(path string) error {
+	return os.MkdirAll(filepath.Dir(path), 0755)
+}
+
+// getBackupDir returns the directory holding earlier versions of the data
+// file, next to the file itself.
+func getBackupDir() (string, error) {
+	path, err := getDataFilePath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "-backups", nil
 }
 
 func LoadData() (*TimeData, error) {
@@ -19,39 +97,155 @@ func LoadData() (*TimeData, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	data := &TimeData{Entries: []TimeEntry{}}
-
-	file, err := os.ReadFile(path)
+	store, err := openStore()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return data, nil
-		}
 		return nil, err
 	}
-
-	if len(file) == 0 {
-		return data, nil
+	data, err := store.Load()
+	if err != nil {
+		return nil, withCode(codeStorage, err)
 	}
+	snapshotEntries(path, data)
+	return data, nil
+}
 
-	err = json.Unmarshal(file, data)
+// LoadDataBetween returns only the entries overlapping [from, to), for
+// reports that do not need the whole history. Zero bounds are open. The
+// result must not be passed to SaveData.
+func LoadDataBetween(from, to time.Time) (*TimeData, error) {
+	store, err := openStore()
 	if err != nil {
 		return nil, err
 	}
-
+	data, err := store.LoadRange(from, to)
+	if err != nil {
+		return nil, withCode(codeStorage, err)
+	}
 	return data, nil
 }
 
+// SaveData writes data back to the active store and records what changed
+// since LoadData in the journal, for undo. Callers that load, modify and
+// save should hold lockData.
 func SaveData(data *TimeData) error {
 	path, err := getDataFilePath()
 	if err != nil {
 		return err
 	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	data.LastActivity = time.Now()
+	if err := store.Save(data); err != nil {
+		return withCode(codeStorage, err)
+	}
+	saveState(data)
+	recordJournal(path, data)
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and
+// renames it over path, so a crash never leaves a truncated file behind.
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
 
-	file, err := json.MarshalIndent(data, "", "  ")
+// backupDataFile keeps the current data file in the backup directory before
+// it is replaced, dropping all but the newest maxBackups.
+func backupDataFile(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	dir, err := getBackupDir()
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Timestamps sort in order, so the newest backup is always last
+	backup := filepath.Join(dir, time.Now().Format("20060102-150405.000000000")+".json")
+	if err := os.Link(path, backup); err != nil {
+		if err := copyFile(path, backup); err != nil {
+			return err
+		}
+	}
 
-	return os.WriteFile(path, file, 0644)
+	backups, err := listBackups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > maxBackups {
+		os.Remove(filepath.Join(dir, backups[0]))
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// listBackups returns the backup file names, oldest first.
+func listBackups() ([]string, error) {
+	dir, err := getBackupDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
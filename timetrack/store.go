@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Store keeps the time entries. The service functions reach it through
+// LoadData and SaveData, which pick the store from the data file's extension.
+type Store interface {
+	// Load returns all entries.
+	Load() (*TimeData, error)
+	// LoadRange returns the entries overlapping [from, to); zero bounds are
+	// open. The result is for reading only and must not be saved.
+	LoadRange(from, to time.Time) (*TimeData, error)
+	// Save writes data back, replacing what Load returned.
+	Save(data *TimeData) error
+	Close() error
+}
+
+// openStores caches stores by path, so the SQLite store keeps its
+// connection and what it loaded between LoadData and SaveData.
+var openStores = make(map[string]Store)
+
+func openStore() (Store, error) {
+	path, err := getDataFilePath()
+	if err != nil {
+		return nil, err
+	}
+	if store, ok := openStores[path]; ok {
+		return store, nil
+	}
+	store := newStore(path)
+	openStores[path] = store
+	return store, nil
+}
+
+func newStore(path string) Store {
+	if isSQLitePath(path) {
+		return &sqliteStore{path: path}
+	}
+	return &jsonStore{path: path}
+}
+
+func isSQLitePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return true
+	}
+	return false
+}
+
+// entryOverlaps reports whether e has any time in [from, to). Zero bounds
+// are open; a running entry extends to now.
+func entryOverlaps(e *TimeEntry, from, to time.Time) bool {
+	if !to.IsZero() && !e.StartTime.Before(to) {
+		return false
+	}
+	if !from.IsZero() && e.EndTime != nil && !e.EndTime.After(from) {
+		return false
+	}
+	return true
+}
+
+// jsonStore is the original single-file store: the whole file is read and
+// rewritten by every command.
+type jsonStore struct {
+	path string
+}
+
+func (s *jsonStore) Load() (*TimeData, error) {
+	data := &TimeData{Entries: []TimeEntry{}}
+
+	file, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	if len(file) == 0 {
+		return data, nil
+	}
+
+	err = json.Unmarshal(file, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is corrupt (%v); run 'timetrack restore-backup' to recover", s.path, err)
+	}
+
+	return data, nil
+}
+
+func (s *jsonStore) LoadRange(from, to time.Time) (*TimeData, error) {
+	data, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := data.Entries[:0]
+	for _, entry := range data.Entries {
+		if entryOverlaps(&entry, from, to) {
+			entries = append(entries, entry)
+		}
+	}
+	data.Entries = entries
+	return data, nil
+}
+
+// Save replaces the file atomically, keeping the previous version as a
+// backup.
+func (s *jsonStore) Save(data *TimeData) error {
+	file, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ensureDataDir(s.path); err != nil {
+		return err
+	}
+	if err := backupDataFile(s.path); err != nil {
+		return fmt.Errorf("failed to back up data: %w", err)
+	}
+
+	return writeFileAtomic(s.path, file)
+}
+
+func (s *jsonStore) Close() error {
+	return nil
+}
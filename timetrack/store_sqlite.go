@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema keeps each entry as JSON, so new TimeEntry fields need no
+// migration, with the times copied into indexed columns for range queries.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS entries (
+	id         TEXT PRIMARY KEY,
+	start_time INTEGER NOT NULL,
+	end_time   INTEGER,
+	entry      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS entries_start_time ON entries (start_time);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);`
+
+// sqliteStore keeps entries in an embedded SQLite database. Save only writes
+// the entries that changed since Load, instead of rewriting everything.
+type sqliteStore struct {
+	path   string
+	db     *sql.DB
+	loaded map[string]string // entry ID -> JSON as of the last Load or Save
+}
+
+func (s *sqliteStore) open() (*sql.DB, error) {
+	if s.db != nil {
+		return s.db, nil
+	}
+
+	if err := ensureDataDir(s.path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", s.path)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection keeps the pragma below in effect for every query
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema in %s: %w", s.path, err)
+	}
+
+	s.db = db
+	return db, nil
+}
+
+// query reads the entries matching where (with its args) plus the metadata.
+func (s *sqliteStore) query(where string, args ...any) (*TimeData, map[string]string, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.Query("SELECT id, entry FROM entries"+where+" ORDER BY start_time, id", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	data := &TimeData{Entries: []TimeEntry{}}
+	raw := make(map[string]string)
+	for rows.Next() {
+		var id, entryJSON string
+		if err := rows.Scan(&id, &entryJSON); err != nil {
+			return nil, nil, err
+		}
+		var entry TimeEntry
+		if err := json.Unmarshal([]byte(entryJSON), &entry); err != nil {
+			return nil, nil, fmt.Errorf("entry %s in %s is corrupt: %w", id, s.path, err)
+		}
+		data.Entries = append(data.Entries, entry)
+		raw[id] = entryJSON
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var lastActivity string
+	err = db.QueryRow("SELECT value FROM meta WHERE key = 'last_activity'").Scan(&lastActivity)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, nil, err
+	default:
+		data.LastActivity, _ = time.Parse(time.RFC3339Nano, lastActivity)
+	}
+
+	return data, raw, nil
+}
+
+func (s *sqliteStore) Load() (*TimeData, error) {
+	data, raw, err := s.query("")
+	if err != nil {
+		return nil, err
+	}
+	s.loaded = raw
+	return data, nil
+}
+
+func (s *sqliteStore) LoadRange(from, to time.Time) (*TimeData, error) {
+	var conds []string
+	var args []any
+	if !to.IsZero() {
+		conds = append(conds, "start_time < ?")
+		args = append(args, to.UnixNano())
+	}
+	if !from.IsZero() {
+		conds = append(conds, "(end_time IS NULL OR end_time > ?)")
+		args = append(args, from.UnixNano())
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	data, _, err := s.query(where, args...)
+	return data, err
+}
+
+// Save writes the entries that were added or changed since the last Load and
+// deletes the ones that were removed, in one transaction.
+func (s *sqliteStore) Save(data *TimeData) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	saved := make(map[string]string, len(data.Entries))
+	for i := range data.Entries {
+		entry := &data.Entries[i]
+		entryJSON, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		saved[entry.ID] = string(entryJSON)
+		if s.loaded[entry.ID] == string(entryJSON) {
+			continue
+		}
+
+		var end sql.NullInt64
+		if entry.EndTime != nil {
+			end = sql.NullInt64{Int64: entry.EndTime.UnixNano(), Valid: true}
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO entries (id, start_time, end_time, entry) VALUES (?, ?, ?, ?)",
+			entry.ID, entry.StartTime.UnixNano(), end, string(entryJSON)); err != nil {
+			return err
+		}
+	}
+
+	for id := range s.loaded {
+		if _, ok := saved[id]; !ok {
+			if _, err := tx.Exec("DELETE FROM entries WHERE id = ?", id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec("INSERT OR REPLACE INTO meta (key, value) VALUES ('last_activity', ?)",
+		data.LastActivity.Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.loaded = saved
+	return nil
+}
+
+func (s *sqliteStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
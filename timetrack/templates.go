@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// outputTemplate is set by --format with a template, either inline
+// ('{{.Title}} {{.Duration}}') or the name of a <command>.<name>.tmpl file in
+// the config directory. list runs it once per entry; status, view and summary
+// run it once.
+var outputTemplate *template.Template
+
+// templateFuncs are available in output templates on top of the built-ins.
+var templateFuncs = template.FuncMap{
+	"date":     func(t time.Time) string { return t.Format(dateLayout) },
+	"clock":    formatClock,
+	"datetime": formatDateTime,
+	"hours":    func(d displayDuration) string { return fmt.Sprintf("%.2f", d.Hours()) },
+	"join":     strings.Join,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"pad":      func(width int, s string) string { return fmt.Sprintf("%-*s", width, s) },
+}
+
+// loadOutputTemplate parses a --format template for command. A format
+// without "{{" names a template file in the config directory, and may not
+// reach outside it.
+func loadOutputTemplate(command, format string) (*template.Template, error) {
+	text := format
+	if !strings.Contains(format, "{{") {
+		if strings.ContainsAny(format, `/\`) || strings.Contains(format, "..") {
+			return nil, fmt.Errorf("invalid format %q (a template name cannot contain path separators or \"..\")", format)
+		}
+		dir, err := getConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		name := command + "." + format + ".tmpl"
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("invalid format %q (expected text, json, a {{...}} template or a template %s in %s)", format, name, dir)
+		}
+		if err != nil {
+			return nil, err
+		}
+		text = string(raw)
+	}
+	return template.New(command).Funcs(templateFuncs).Parse(text)
+}
+
+// printTemplate runs the --format template on v, ending the output with a
+// newline so one-line templates need not spell it out.
+func printTemplate(v any) error {
+	var buf bytes.Buffer
+	if err := outputTemplate.Execute(&buf, v); err != nil {
+		return withCode(codeUsage, fmt.Errorf("failed to render --format template: %w", err))
+	}
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, err := os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// displayDuration prints in templates the way the text output does
+// ("1h 30m"); .Hours and .Minutes give the numbers.
+type displayDuration time.Duration
+
+func (d displayDuration) String() string {
+	return formatDuration(time.Duration(d))
+}
+
+func (d displayDuration) Hours() float64 {
+	return time.Duration(d).Hours()
+}
+
+func (d displayDuration) Minutes() float64 {
+	return time.Duration(d).Minutes()
+}
+
+// entryView is what list and view templates see. End is zero while the
+// entry is running.
+type entryView struct {
+	Index       int
+	ID          string
+	Title       string
+	Project     string
+	Tags        []string
+	Start       time.Time
+	End         time.Time
+	Duration    displayDuration
+	Running     bool
+	Paused      bool
+	Billable    bool
+	AutoStopped bool
+	Notes       string
+}
+
+func newEntryView(e *TimeEntry, index int) entryView {
+	v := entryView{
+		Index:       index,
+		ID:          e.ID,
+		Title:       e.Title,
+		Project:     e.Project,
+		Tags:        e.Tags,
+		Start:       e.StartTime,
+		Duration:    displayDuration(e.Duration()),
+		Running:     e.IsRunning(),
+		Paused:      e.IsPaused(),
+		Billable:    e.IsBillable(),
+		AutoStopped: e.AutoStopped,
+		Notes:       e.Notes,
+	}
+	if e.EndTime != nil {
+		v.End = *e.EndTime
+	}
+	return v
+}
+
+// statusView is what status templates see: the running entry's fields
+// (zero, with .Running false, when nothing runs) and today's progress.
+type statusView struct {
+	entryView
+	Today       displayDuration
+	DailyTarget displayDuration
+}
+
+// summaryView is what summary templates see.
+type summaryView struct {
+	Period    string
+	From      time.Time
+	To        time.Time
+	GroupBy   string
+	Total     displayDuration
+	Entries   int
+	Pomodoros int
+	Groups    []summaryGroupView
+	Days      []summaryDayView
+}
+
+type summaryGroupView struct {
+	Key      string
+	Duration displayDuration
+	Percent  float64
+	First    time.Time
+	Last     time.Time
+	Notes    []string
+}
+
+type summaryDayView struct {
+	Date      time.Time
+	Duration  displayDuration
+	Pomodoros int
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// All commands that accept a time go through parseTimeExpr, which understands:
+//
+//	now
+//	HH:MM, HH:MM:SS, 3pm, 3:15pm          (on the reference day)
+//	YYYY-MM-DD, YYYY-MM-DD HH:MM, ISO 8601 (2026-10-16T17:30, RFC 3339)
+//	today, yesterday, monday..sunday       (optionally followed by a clock time)
+//	10 min ago, 2h ago, 1h30m ago          (relative to now)
+
+var relativeUnitPattern = regexp.MustCompile(`(\d+)\s*([a-z]+)`)
+
+var relativeUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// startOfDay returns midnight of t's day in t's location.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// parseTimeExpr resolves a time expression relative to now. Bare clock
+// times fall on today.
+func parseTimeExpr(s string, now time.Time) (time.Time, error) {
+	return parseTimeExprOn(s, startOfDay(now), now)
+}
+
+// parseTimeExprOn is parseTimeExpr with bare clock times falling on day
+// instead of today, e.g. the day of the entry being edited.
+func parseTimeExprOn(s string, day, now time.Time) (time.Time, error) {
+	expr := strings.ToLower(strings.TrimSpace(s))
+	if expr == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+	if expr == "now" {
+		return now, nil
+	}
+
+	if rest, ok := strings.CutSuffix(expr, " ago"); ok {
+		d, err := parseRelative(rest)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, strings.ToUpper(expr)); err == nil {
+		return t.In(now.Location()), nil
+	}
+	for _, layout := range []string{"2006-01-02t15:04:05", "2006-01-02t15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, expr, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 1:
+		if t, err := parseClock(fields[0], day); err == nil {
+			return t, nil
+		}
+		if d, err := parseDate(fields[0], now); err == nil {
+			return d, nil
+		}
+	case 2:
+		d, err := parseDate(fields[0], now)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return parseClock(fields[1], d)
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (try HH:MM, YYYY-MM-DD HH:MM, yesterday 17:00 or \"10 min ago\")", s)
+}
+
+// parseRelative parses durations such as "10 min", "2 hours" or "1h30m".
+func parseRelative(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(strings.ReplaceAll(s, " ", "")); err == nil {
+		return d, nil
+	}
+
+	matches := relativeUnitPattern.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 || strings.TrimSpace(relativeUnitPattern.ReplaceAllString(s, "")) != "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total time.Duration
+	for _, m := range matches {
+		unit, ok := relativeUnits[m[2]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration unit %q", m[2])
+		}
+		n, _ := strconv.Atoi(m[1])
+		total += time.Duration(n) * unit
+	}
+	return total, nil
+}
+
+// parseDate resolves "today", "yesterday", a weekday name (the most recent
+// such day, counting today) or a YYYY-MM-DD date to midnight of that day in
+// now's location.
+func parseDate(s string, now time.Time) (time.Time, error) {
+	expr := strings.ToLower(strings.TrimSpace(s))
+	today := startOfDay(now)
+	switch expr {
+	case "", "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if weekday, ok := weekdays[expr]; ok {
+		back := (int(now.Weekday()) - int(weekday) + 7) % 7
+		return today.AddDate(0, 0, -back), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", expr, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected today, yesterday, a weekday or YYYY-MM-DD)", s)
+	}
+	return day, nil
+}
+
+// parseClock returns the time of day s (HH:MM, HH:MM:SS, 3pm or 3:15pm) on
+// the given day.
+func parseClock(s string, day time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, layout := range []string{"15:04", "15:04:05", "3:04pm", "3pm"} {
+		clock, err := time.Parse(layout, s)
+		if err == nil {
+			return time.Date(day.Year(), day.Month(), day.Day(),
+				clock.Hour(), clock.Minute(), clock.Second(), 0, day.Location()), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+}
+
+// resolveTimeArg interprets an edit time argument for a value currently set
+// to base. A signed whole number keeps its historic meaning of shifting base
+// by that many minutes; anything else is a time expression whose bare clock
+// times fall on base's day.
+func resolveTimeArg(s string, base, now time.Time) (time.Time, error) {
+	if mins, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return base.Add(time.Duration(mins) * time.Minute), nil
+	}
+	return parseTimeExprOn(s, startOfDay(base), now)
+}
+
+// parseRangeEnd parses the upper bound of a date range. A bare day such as
+// "2026-09-30" or "yesterday" includes the whole of that day.
+func parseRangeEnd(s string, now time.Time) (time.Time, error) {
+	if day, err := parseDate(s, now); err == nil {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return parseTimeExpr(s, now)
+}
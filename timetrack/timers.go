@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// stopExpiredTimebox stops a running entry whose planned end has passed, at
+// the planned end rather than now, so a finished timebox does not keep
+// counting until the next stop. main runs it before every command.
+func stopExpiredTimebox() error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	running := findRunningTask(data)
+	if running == nil || running.PlannedEnd == nil || running.PlannedEnd.After(time.Now()) {
+		return nil
+	}
+
+	running.Stop(*running.PlannedEnd)
+
+	if err := SaveData(data); err != nil {
+		return fmt.Errorf("failed to save data: %w", err)
+	}
+	queueHook("stop", running)
+
+	textf("Timebox ended: %s (stopped at %s after %s)\n",
+		running.Title, formatClock(*running.PlannedEnd), formatDuration(running.Duration()))
+	return nil
+}
+
+// capForgottenTimer stops a running entry that has outlived the auto_stop
+// rules in the config, flagging it for review. The user is asked whether to
+// stop it at the cap or at the last recorded activity; assumeYes picks the
+// cap without asking. Without a terminal to ask on it only warns.
+func capForgottenTimer(assumeYes bool) error {
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	running := findRunningTask(data)
+	if running == nil {
+		return nil
+	}
+	limit, ok, err := config.AutoStop.CapFor(running)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !ok || limit.After(now) {
+		return nil
+	}
+
+	stopAt := limit
+	if !assumeYes {
+		if !isTerminal(os.Stdin) {
+			fmt.Fprintf(os.Stderr, "Warning: %s has been running since %s; run with --yes to stop it at %s\n",
+				running.Title, running.StartTime.Format("Mon "+clockLayout), limit.Format("Mon "+clockLayout))
+			return nil
+		}
+
+		lastActivity := data.LastActivity
+		if lastActivity.Before(running.StartTime) {
+			lastActivity = running.StartTime
+		}
+		fmt.Fprintf(os.Stderr, "%s has been running for %s (since %s).\n",
+			running.Title, formatDuration(running.Duration()), running.StartTime.Format("Mon "+clockLayout))
+		fmt.Fprintf(os.Stderr, "Stop it at the [c]ap (%s), [l]ast activity (%s), [n]ow, or [k]eep it running? [c] ",
+			limit.Format("Mon "+clockLayout), lastActivity.Format("Mon "+clockLayout))
+
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Fprintln(os.Stderr, "\nNo answer, keeping it running; run with --yes to stop it at the cap")
+			return nil
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "", "c", "cap":
+		case "l", "last":
+			stopAt = lastActivity
+		case "n", "now":
+			return stopForgottenTimer(running.ID, now, false)
+		default:
+			return nil
+		}
+	}
+
+	return stopForgottenTimer(running.ID, stopAt, true)
+}
+
+// stopForgottenTimer stops the entry with the given ID if it is still
+// running. It reloads the data under the lock, since the prompt above may
+// have waited on the user while another process changed the file.
+func stopForgottenTimer(id string, stopAt time.Time, flag bool) error {
+	unlock, err := lockData()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	running := findRunningTask(data)
+	if running == nil || running.ID != id {
+		return nil
+	}
+
+	running.Stop(stopAt)
+	running.AutoStopped = flag
+
+	if err := SaveData(data); err != nil {
+		return fmt.Errorf("failed to save data: %w", err)
+	}
+	queueHook("stop", running)
+
+	if !flag {
+		textf("Stopped: %s (ran for %s)\n", running.Title, formatDuration(running.Duration()))
+		return nil
+	}
+	textf("Auto-stopped: %s at %s after %s (flagged for review, see list --auto-stopped)\n",
+		running.Title, stopAt.Format("Mon "+clockLayout), formatDuration(running.Duration()))
+	return nil
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe
+// or file.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
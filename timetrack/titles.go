@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// normalizeTitle folds case and whitespace so "fix  Login" matches "Fix login".
+func normalizeTitle(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// priorTitles returns the distinct titles in data, most recently used first.
+func priorTitles(data *TimeData) []string {
+	var titles []string
+	seen := make(map[string]bool)
+	for _, idx := range getSortedIndices(data.Entries) {
+		title := data.Entries[idx].Title
+		if !seen[title] {
+			seen[title] = true
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// fuzzyScore rates how well query matches title; lower is better. The second
+// result is false when they do not match at all.
+func fuzzyScore(query, title string) (int, bool) {
+	q, t := normalizeTitle(query), normalizeTitle(title)
+	switch {
+	case q == "":
+		return 5, true
+	case q == t:
+		return 0, true
+	case strings.HasPrefix(t, q):
+		return 1, true
+	case strings.Contains(t, q):
+		return 2, true
+	case isSubsequence(q, t):
+		return 3, true
+	case levenshtein(q, t) <= max(2, len(t)/5):
+		return 4, true
+	}
+	return 0, false
+}
+
+func isSubsequence(q, t string) bool {
+	qr := []rune(q)
+	i := 0
+	for _, r := range t {
+		if i < len(qr) && qr[i] == r {
+			i++
+		}
+	}
+	return i == len(qr)
+}
+
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur := make([]int, len(br)+1)
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(br)]
+}
+
+// matchTitles returns prior titles matching query, best match first and
+// most recently used first among equals.
+func matchTitles(data *TimeData, query string) []string {
+	type match struct {
+		title string
+		score int
+	}
+	var matches []match
+	for _, title := range priorTitles(data) {
+		if score, ok := fuzzyScore(query, title); ok {
+			matches = append(matches, match{title, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	titles := make([]string, len(matches))
+	for i, m := range matches {
+		titles[i] = m.title
+	}
+	return titles
+}
+
+// canonicalTitle returns the prior title that title differs from only in
+// case or spacing, so restarting a task keeps it on one summary row.
+func canonicalTitle(data *TimeData, title string) (string, bool) {
+	norm := normalizeTitle(title)
+	for _, prior := range priorTitles(data) {
+		if normalizeTitle(prior) == norm {
+			return prior, true
+		}
+	}
+	return "", false
+}
+
+// ListTitles prints prior titles matching query, one per line, for shell
+// completion and for picking a task to restart.
+func ListTitles(query string, limit int) error {
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	titles := matchTitles(data, query)
+	if limit > 0 && len(titles) > limit {
+		titles = titles[:limit]
+	}
+	if jsonOutput {
+		if titles == nil {
+			titles = []string{}
+		}
+		return printJSON(map[string]any{"titles": titles})
+	}
+	for _, title := range titles {
+		textln(title)
+	}
+	return nil
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const tuiHelp = "↑↓ move  / filter  s start  c continue  x stop  p pause  e title  b/f start/end  n note  d delete  u/U undo/redo  q quit"
+
+// tuiPrompt is a line of input at the bottom of the TUI. With confirm set it
+// is a yes/no question answered by a single key.
+type tuiPrompt struct {
+	label   string
+	input   []rune
+	confirm bool
+	submit  func(string) error
+}
+
+// tui is the state of the full-screen interface. Every change goes through
+// the same service functions as the CLI; their output becomes the status
+// message.
+type tui struct {
+	data      *TimeData
+	path      string
+	modTime   time.Time
+	rows      []int // indices into data.Entries, newest first, filtered
+	positions []int // the IDX list shows for each row
+	cursor    int
+	offset    int
+	filter    []rune
+	filtering bool
+	prompt    *tuiPrompt
+	message   string
+	output    bytes.Buffer
+}
+
+// RunTUI runs the full-screen interface until the user quits.
+func RunTUI() error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return withCode(codeUsage, fmt.Errorf("tui needs a terminal"))
+	}
+
+	path, err := getDataFilePath()
+	if err != nil {
+		return err
+	}
+	t := &tui{path: path}
+	if err := t.reload(); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(in, state)
+	fmt.Print("\033[?1049h\033[?25l") // alternate screen, hidden cursor
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	textOut, warningOut = &t.output, &t.output
+	defer func() { textOut, warningOut = os.Stdout, os.Stderr }()
+
+	keys := make(chan []byte)
+	go func() {
+		defer close(keys)
+		for {
+			buf := make([]byte, 64)
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			keys <- buf[:n]
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Print(t.render(width, height, time.Now()))
+
+		select {
+		case b, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range decodeKeys(b) {
+				if t.handle(key, height) {
+					return nil
+				}
+			}
+		case <-ticker.C:
+			t.tick()
+		}
+	}
+}
+
+// reload reads the data again and rebuilds the filtered rows, keeping the
+// cursor on the same entry when it is still listed.
+func (t *tui) reload() error {
+	var selected string
+	if e := t.selected(); e != nil {
+		selected = e.ID
+	}
+
+	data, err := LoadData()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	t.data = data
+	if info, err := os.Stat(t.path); err == nil {
+		t.modTime = info.ModTime()
+	}
+
+	t.rows, t.positions = nil, nil
+	query := normalizeTitle(string(t.filter))
+	for i, idx := range getSortedIndices(data.Entries) {
+		if query == "" || tuiMatches(&data.Entries[idx], query) {
+			t.rows = append(t.rows, idx)
+			t.positions = append(t.positions, i)
+		}
+	}
+
+	for i, idx := range t.rows {
+		if data.Entries[idx].ID == selected {
+			t.cursor = i
+		}
+	}
+	t.cursor = max(0, min(t.cursor, len(t.rows)-1))
+	return nil
+}
+
+// tuiMatches reports whether an entry matches a filter typed in the TUI.
+func tuiMatches(e *TimeEntry, query string) bool {
+	text := normalizeTitle(e.Title + " " + e.Project + " " + strings.Join(e.Tags, " "))
+	return strings.Contains(text, query) || strings.HasPrefix(e.ID, query)
+}
+
+// tick picks up changes made from other terminals and ends an expired
+// timebox, as every CLI command does on start.
+func (t *tui) tick() {
+	if running := findRunningTask(t.data); running != nil && running.PlannedEnd != nil && !running.PlannedEnd.After(time.Now()) {
+		t.run(stopExpiredTimebox)
+		return
+	}
+	if info, err := os.Stat(t.path); err == nil && !info.ModTime().Equal(t.modTime) {
+		if err := t.reload(); err != nil {
+			t.message = "Error: " + err.Error()
+		}
+	}
+}
+
+// run calls a service function, shows its last line of output (or its
+// error) as the status message and reloads the data.
+func (t *tui) run(fn func() error) {
+	t.output.Reset()
+	err := fn()
+	t.message = ""
+	for _, line := range strings.Split(strings.TrimSpace(t.output.String()), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			t.message = line
+		}
+	}
+	if err != nil {
+		t.message = "Error: " + err.Error()
+	}
+	if err := t.reload(); err != nil {
+		t.message = "Error: " + err.Error()
+	}
+}
+
+func (t *tui) selected() *TimeEntry {
+	if t.data == nil || t.cursor < 0 || t.cursor >= len(t.rows) {
+		return nil
+	}
+	return &t.data.Entries[t.rows[t.cursor]]
+}
+
+// ask opens a prompt prefilled with value.
+func (t *tui) ask(label, value string, submit func(string) error) {
+	t.prompt = &tuiPrompt{label: label, input: []rune(value), submit: submit}
+}
+
+// handle applies one key and reports whether the TUI should quit.
+func (t *tui) handle(key string, height int) bool {
+	if key == "ctrl-c" {
+		return true
+	}
+
+	if p := t.prompt; p != nil {
+		switch {
+		case p.confirm:
+			t.prompt = nil
+			if key == "y" || key == "Y" {
+				t.run(func() error { return p.submit("") })
+			} else {
+				t.message = "Cancelled"
+			}
+		case key == "esc":
+			t.prompt, t.message = nil, "Cancelled"
+		case key == "enter":
+			t.prompt = nil
+			if value := strings.TrimSpace(string(p.input)); value != "" {
+				t.run(func() error { return p.submit(value) })
+			}
+		default:
+			p.input = editInput(p.input, key)
+		}
+		return false
+	}
+
+	if t.filtering {
+		switch key {
+		case "esc":
+			t.filtering, t.filter = false, nil
+		case "enter":
+			t.filtering = false
+		default:
+			t.filter = editInput(t.filter, key)
+		}
+		t.cursor = 0
+		if err := t.reload(); err != nil {
+			t.message = "Error: " + err.Error()
+		}
+		return false
+	}
+
+	page := max(1, height-tuiChrome(height))
+	entry := t.selected()
+	switch key {
+	case "q":
+		return true
+	case "up", "k":
+		t.cursor--
+	case "down", "j":
+		t.cursor++
+	case "pgup":
+		t.cursor -= page
+	case "pgdn":
+		t.cursor += page
+	case "home", "g":
+		t.cursor = 0
+	case "end", "G":
+		t.cursor = len(t.rows) - 1
+	case "/":
+		t.filtering = true
+	case "r":
+		t.run(func() error { return nil })
+	case "s":
+		t.ask("Start: ", "", func(title string) error { return StartTask(title, StartOptions{}) })
+	case "x":
+		t.run(func() error { return StopTask(time.Time{}) })
+	case "p":
+		if running := findRunningTask(t.data); running != nil && running.IsPaused() {
+			t.run(ResumeTask)
+		} else {
+			t.run(PauseTask)
+		}
+	case "u":
+		t.run(Undo)
+	case "U":
+		t.run(Redo)
+	}
+
+	if entry != nil {
+		switch key {
+		case "c", "enter":
+			t.run(func() error { return ContinueTask(entry.ID) })
+		case "e":
+			t.ask("Title: ", entry.Title, func(title string) error { return EditTask(entry.ID, EditOptions{Title: title}) })
+		case "b":
+			t.ask("Start time: ", entry.StartTime.Format("15:04"), func(start string) error {
+				return EditTask(entry.ID, EditOptions{Start: start})
+			})
+		case "f":
+			if entry.EndTime == nil {
+				t.message = "Error: cannot adjust end time for a running task"
+				break
+			}
+			t.ask("End time: ", entry.EndTime.Format("15:04"), func(end string) error {
+				return EditTask(entry.ID, EditOptions{End: end})
+			})
+		case "n":
+			t.ask("Note: ", "", func(note string) error { return NoteTask(entry.ID, note) })
+		case "d":
+			t.prompt = &tuiPrompt{
+				label:   fmt.Sprintf("Delete %q? [y/N] ", entry.Title),
+				confirm: true,
+				submit:  func(string) error { return DeleteTask(entry.ID) },
+			}
+		}
+	}
+
+	t.cursor = max(0, min(t.cursor, len(t.rows)-1))
+	return false
+}
+
+// editInput applies a key to a line of input.
+func editInput(input []rune, key string) []rune {
+	switch {
+	case key == "backspace":
+		if len(input) > 0 {
+			input = input[:len(input)-1]
+		}
+	case key == "ctrl-u":
+		input = nil
+	case utf8.RuneCountInString(key) == 1:
+		input = append(input, []rune(key)...)
+	}
+	return input
+}
+
+// tuiChrome is the number of lines around the entry list: the header, the
+// today/week panes (on tall enough terminals) and the footer.
+func tuiChrome(height int) int {
+	if height >= 24 {
+		return 16
+	}
+	return 7
+}
+
+// render draws a full frame for a terminal of the given size.
+func (t *tui) render(width, height int, now time.Time) string {
+	var lines []string
+	lines = append(lines, t.header(now), "")
+	if height >= 24 {
+		lines = append(lines, t.panes(width, now)...)
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, strings.Repeat("─", width))
+	lines = append(lines, fmt.Sprintf("  %-4s %-9s %-17s %-9s %s", "IDX", "ID", "START", "DURATION", "TITLE"))
+
+	visible := max(1, height-tuiChrome(height))
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+visible {
+		t.offset = t.cursor - visible + 1
+	}
+	for i := t.offset; i < t.offset+visible; i++ {
+		if i >= len(t.rows) {
+			lines = append(lines, "")
+			continue
+		}
+		e := &t.data.Entries[t.rows[i]]
+		duration := formatDuration(e.Duration())
+		switch {
+		case e.IsPaused():
+			duration += " ‖"
+		case e.IsRunning():
+			duration += " ▶"
+		}
+		row := fmt.Sprintf("%-4d %-9s %-17s %-9s %s%s", t.positions[i], e.ID, formatDateTime(e.StartTime), duration, e.Title, formatMeta(e))
+		if i == t.cursor {
+			lines = append(lines, "\033[7m"+fitWidth("> "+row, width)+"\033[0m")
+			continue
+		}
+		lines = append(lines, "  "+row)
+	}
+
+	lines = append(lines, strings.Repeat("─", width))
+	switch {
+	case t.prompt != nil:
+		lines = append(lines, t.prompt.label+string(t.prompt.input)+"█")
+	case t.filtering:
+		lines = append(lines, "/"+string(t.filter)+"█")
+	case len(t.filter) > 0:
+		lines = append(lines, fmt.Sprintf("Filter: %s (%d of %d)  %s", string(t.filter), len(t.rows), len(t.data.Entries), t.message))
+	default:
+		lines = append(lines, t.message)
+	}
+	lines = append(lines, tuiHelp)
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		if !strings.HasPrefix(line, "\033[") {
+			line = fitWidth(line, width)
+		}
+		b.WriteString(line + "\033[K")
+	}
+	b.WriteString("\033[J")
+	return b.String()
+}
+
+// header describes the running task.
+func (t *tui) header(now time.Time) string {
+	running := findRunningTask(t.data)
+	switch {
+	case running == nil:
+		return "timetrack  No task is currently running"
+	case running.IsPaused():
+		return fmt.Sprintf("timetrack  Paused: %s%s  %s", running.Title, formatMeta(running), formatElapsed(running.Duration()))
+	}
+	header := fmt.Sprintf("timetrack  Running: %s%s  %s", running.Title, formatMeta(running), formatElapsed(running.Duration()))
+	if running.PlannedEnd != nil {
+		header += fmt.Sprintf(" (%s left)", formatElapsed(running.PlannedEnd.Sub(now)))
+	}
+	return header
+}
+
+// panes renders today's top tasks beside this week's daily totals.
+func (t *tui) panes(width int, now time.Time) []string {
+	today := startOfDay(now)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	var todayTotal time.Duration
+	byTitle := make(map[string]time.Duration)
+	for i := range t.data.Entries {
+		if d := t.data.Entries[i].DurationWithin(today, tomorrow); d > 0 {
+			byTitle[t.data.Entries[i].Title] += d
+			todayTotal += d
+		}
+	}
+	titles := make([]string, 0, len(byTitle))
+	for title := range byTitle {
+		titles = append(titles, title)
+	}
+	sort.Slice(titles, func(i, j int) bool {
+		if byTitle[titles[i]] != byTitle[titles[j]] {
+			return byTitle[titles[i]] > byTitle[titles[j]]
+		}
+		return titles[i] < titles[j]
+	})
+
+	left := []string{"Today: " + formatDuration(todayTotal)}
+	if dailyTarget > 0 {
+		percent := percentOf(todayTotal, dailyTarget)
+		left[0] += fmt.Sprintf(" of %s (%.0f%%)", formatDuration(dailyTarget), percent)
+		left = append(left, progressBar(percent, 20))
+	}
+	for _, title := range titles {
+		if len(left) == 8 {
+			break
+		}
+		left = append(left, fmt.Sprintf("  %-9s %s", formatDuration(byTitle[title]), title))
+	}
+
+	var right []string
+	if from, to, _, err := periodRange("week", "", now, defaultWeekStart()); err == nil {
+		var weekTotal time.Duration
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			d := trackedOn(t.data, day)
+			weekTotal += d
+			right = append(right, fmt.Sprintf("  %s  %s", day.Format("Mon 2 Jan"), formatDuration(d)))
+		}
+		right = append([]string{"This week: " + formatDuration(weekTotal)}, right...)
+	}
+
+	half := width / 2
+	lines := make([]string, 8)
+	for i := range lines {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		lines[i] = fitWidth(l, half) + r
+	}
+	return lines
+}
+
+// fitWidth pads or truncates s to exactly width columns (counting runes).
+func fitWidth(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// decodeKeys turns bytes read from a raw terminal into key names ("up",
+// "enter", "esc", ...) or single characters.
+func decodeKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b && len(b) > 2 && (b[1] == '[' || b[1] == 'O'):
+			i := 2
+			for i < len(b)-1 && (b[i] < 0x40 || b[i] > 0x7e) {
+				i++
+			}
+			if name, ok := escapeKeys[string(b[2:i+1])]; ok {
+				keys = append(keys, name)
+			}
+			b = b[i+1:]
+		case c == 0x1b:
+			keys = append(keys, "esc")
+			b = b[1:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+			b = b[1:]
+		case c == 0x15:
+			keys = append(keys, "ctrl-u")
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+// escapeKeys maps the CSI and SS3 sequences of common terminals to keys.
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end", "1~": "home", "4~": "end",
+	"5~": "pgup", "6~": "pgdn", "3~": "delete",
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Watch redraws the running entry, its elapsed time and today's progress in
+// place every interval until interrupted. Starts and stops made from other
+// terminals are picked up by polling the data file's modification time, so
+// the data is only reloaded when it changes. With --json it writes one line
+// of JSON per tick instead.
+func Watch(interval time.Duration) error {
+	path, err := getDataFilePath()
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	redraw := plainText() && isTerminal(os.Stdout)
+	if redraw {
+		fmt.Print("\033[?25l") // hide the cursor while redrawing
+		defer fmt.Print("\033[?25h")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var data *TimeData
+	var modTime, day time.Time
+	drawn := 0
+	for {
+		now := time.Now()
+		reload := data == nil || !startOfDay(now).Equal(day)
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(modTime) {
+			modTime, reload = info.ModTime(), true
+		}
+		if running := watchedEntry(data); running != nil && running.PlannedEnd != nil && !running.PlannedEnd.After(now) {
+			if err := stopExpiredTimebox(); err != nil {
+				return err
+			}
+			// Leave the "Timebox ended" line above the next frame
+			reload, drawn = true, 0
+		}
+		if reload {
+			day = startOfDay(now)
+			if data, err = LoadDataBetween(day, day.AddDate(0, 0, 1)); err != nil {
+				return fmt.Errorf("failed to load data: %w", err)
+			}
+		}
+
+		running := watchedEntry(data)
+		today := trackedOn(data, now)
+		switch {
+		case jsonOutput:
+			if err := printWatchJSON(running, today); err != nil {
+				return err
+			}
+		case outputTemplate != nil:
+			view := statusView{Today: displayDuration(today), DailyTarget: displayDuration(dailyTarget)}
+			if running != nil {
+				view.entryView = newEntryView(running, 0)
+			}
+			if err := printTemplate(view); err != nil {
+				return err
+			}
+		default:
+			lines := watchLines(running, today)
+			if redraw && drawn > 0 {
+				fmt.Printf("\033[%dA", drawn) // back to the first line
+			}
+			for _, line := range lines {
+				if redraw {
+					fmt.Print("\033[2K") // clear what was there
+				}
+				fmt.Println(line)
+			}
+			drawn = len(lines)
+		}
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchedEntry is findRunningTask for data that may not be loaded yet.
+func watchedEntry(data *TimeData) *TimeEntry {
+	if data == nil {
+		return nil
+	}
+	return findRunningTask(data)
+}
+
+// watchLines renders one frame of watch.
+func watchLines(running *TimeEntry, today time.Duration) []string {
+	var lines []string
+	switch {
+	case running == nil:
+		lines = append(lines, "No task is currently running", "")
+	case running.IsPaused():
+		lines = append(lines,
+			fmt.Sprintf("Paused:  %s%s [%s]", running.Title, formatMeta(running), running.ID),
+			fmt.Sprintf("Elapsed: %s (paused)", formatElapsed(running.Duration())))
+	default:
+		elapsed := fmt.Sprintf("Elapsed: %s since %s", formatElapsed(running.Duration()), formatClock(running.StartTime))
+		if running.PlannedEnd != nil {
+			elapsed += fmt.Sprintf(", %s left", formatElapsed(time.Until(*running.PlannedEnd)))
+		}
+		lines = append(lines, fmt.Sprintf("Running: %s%s [%s]", running.Title, formatMeta(running), running.ID), elapsed)
+	}
+
+	if dailyTarget > 0 {
+		percent := percentOf(today, dailyTarget)
+		lines = append(lines, fmt.Sprintf("Today:   %s of %s target (%.0f%%)", formatElapsed(today), formatDuration(dailyTarget), percent),
+			"         "+progressBar(percent, 30))
+	} else {
+		lines = append(lines, fmt.Sprintf("Today:   %s", formatElapsed(today)))
+	}
+	return lines
+}
+
+// printWatchJSON writes one tick of watch --json as a single line.
+func printWatchJSON(running *TimeEntry, today time.Duration) error {
+	return json.NewEncoder(os.Stdout).Encode(statusJSON{
+		Running:            newEntryJSON(running),
+		TodaySeconds:       int64(today.Seconds()),
+		DailyTargetSeconds: int64(dailyTarget.Seconds()),
+	})
+}
+
+// formatElapsed formats d as a ticking clock, e.g. "1:12:05".
+func formatElapsed(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	s := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// progressBar draws percent (capped at 100) as a bar of width cells.
+func progressBar(percent float64, width int) string {
+	filled := int(percent / 100 * float64(width))
+	filled = max(0, min(filled, width))
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}